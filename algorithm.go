@@ -0,0 +1,177 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// freshAlgorithm is implemented by the built-in algorithms to copy their parameters without the state,
+// so each key of ConcurrencyLimit gets its own algorithm.
+type freshAlgorithm interface {
+	fresh() LimitAlgorithm
+}
+
+// lockedAlgorithm serializes the updates of an algorithm shared by the limiters of ConcurrencyLimit
+type lockedAlgorithm struct {
+	lock sync.Mutex
+	algo LimitAlgorithm
+}
+
+func (a *lockedAlgorithm) Update(limit int64, rtt time.Duration, inFlight int64, dropped bool) int64 {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.algo.Update(limit, rtt, inFlight, dropped)
+}
+
+// Vegas implements the TCP Vegas congestion control algorithm for concurrency limits.
+// The queue size is estimated by limit * (1 - rttNoLoad/rtt), the limit increases
+// when the queue is short and decreases when the queue is long.
+// https://github.com/Netflix/concurrency-limits
+type Vegas struct {
+	rttNoLoad time.Duration
+}
+
+// NewVegas returns the Vegas algorithm
+func NewVegas() *Vegas {
+	return &Vegas{}
+}
+
+func (v *Vegas) fresh() LimitAlgorithm {
+	return NewVegas()
+}
+
+// Update implements LimitAlgorithm
+func (v *Vegas) Update(limit int64, rtt time.Duration, inFlight int64, dropped bool) int64 {
+	if v.rttNoLoad == 0 || rtt < v.rttNoLoad {
+		// new no load rtt found, wait for more samples
+		v.rttNoLoad = rtt
+		return limit
+	}
+	step := log10Limit(limit)
+	if dropped {
+		return limit - step
+	}
+	if inFlight*2 < limit {
+		// app limited, the limit is not the bottleneck
+		return limit
+	}
+	queueSize := int64(math.Ceil(float64(limit) * (1 - float64(v.rttNoLoad)/float64(rtt))))
+	switch {
+	case queueSize <= step: // threshold
+		return limit + 6*step
+	case queueSize < 3*step: // alpha
+		return limit + step
+	case queueSize > 6*step: // beta
+		return limit - step
+	}
+	return limit
+}
+
+// Gradient2 adjusts the limit by the gradient between a long term RTT average
+// and the latest RTT, inspired by Netflix Gradient2Limit.
+type Gradient2 struct {
+	tolerance float64
+	longRtt   float64
+	estimate  float64
+	samples   int
+}
+
+const (
+	gradient2Window  = 600
+	gradient2Warmup  = 10
+	gradient2Smooth  = 0.2
+	gradient2MinGrad = 0.5
+)
+
+// NewGradient2 returns the Gradient2 algorithm,
+// tolerance defines how much the latest RTT can exceed the long term RTT before the limit decreases, e.g. 1.5
+func NewGradient2(tolerance float64) *Gradient2 {
+	if tolerance < 1 {
+		tolerance = 1
+	}
+	return &Gradient2{tolerance: tolerance}
+}
+
+func (g *Gradient2) fresh() LimitAlgorithm {
+	return NewGradient2(g.tolerance)
+}
+
+// Update implements LimitAlgorithm
+func (g *Gradient2) Update(limit int64, rtt time.Duration, inFlight int64, dropped bool) int64 {
+	shortRtt := float64(rtt)
+	if g.samples < gradient2Warmup {
+		g.samples++
+		g.longRtt += (shortRtt - g.longRtt) / float64(g.samples)
+	} else {
+		factor := 2.0 / (gradient2Window + 1)
+		g.longRtt = g.longRtt*(1-factor) + shortRtt*factor
+	}
+	// recover faster when the long term RTT drifts too high
+	if g.longRtt/shortRtt > 2 {
+		g.longRtt *= 0.95
+	}
+	if int64(g.estimate) != limit {
+		// the limit may have been clamped by the limiter
+		g.estimate = float64(limit)
+	}
+	if inFlight < limit/2 {
+		return limit
+	}
+
+	gradient := math.Max(gradient2MinGrad, math.Min(1, g.tolerance*g.longRtt/shortRtt))
+	newLimit := g.estimate*gradient + math.Sqrt(g.estimate)
+	g.estimate = g.estimate*(1-gradient2Smooth) + newLimit*gradient2Smooth
+	return int64(g.estimate)
+}
+
+// AIMD increases the limit by one while requests are healthy and
+// decreases it multiplicatively when requests are dropped or exceed timeout.
+type AIMD struct {
+	timeout      time.Duration
+	backoffRatio float64
+}
+
+// NewAIMD returns the AIMD algorithm, requests slower than timeout are treated as drops
+func NewAIMD(timeout time.Duration) *AIMD {
+	return &AIMD{
+		timeout:      timeout,
+		backoffRatio: 0.9,
+	}
+}
+
+func (a *AIMD) fresh() LimitAlgorithm {
+	return &AIMD{timeout: a.timeout, backoffRatio: a.backoffRatio}
+}
+
+// Update implements LimitAlgorithm
+func (a *AIMD) Update(limit int64, rtt time.Duration, inFlight int64, dropped bool) int64 {
+	if dropped || rtt > a.timeout {
+		return int64(float64(limit) * a.backoffRatio)
+	}
+	if inFlight*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// log10Limit returns log10(limit) with the minimum of 1
+func log10Limit(limit int64) int64 {
+	return int64(math.Max(1, math.Log10(float64(limit))))
+}
@@ -23,17 +23,80 @@ import (
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
 )
 
+// Limiter is implemented by every limiter in this package.
+type Limiter interface {
+	// Allow reports whether the request can pass, the returned func must be called once it's done.
+	Allow() (func(), error)
+}
+
 //	AdaptiveLimit CPU sampling algorithm using BBR
 func AdaptiveLimit(opts ...Option) app.HandlerFunc {
-	limiter := NewLimiter(opts...)
-	return func(c context.Context, ctx *app.RequestContext) {
-		done, err := limiter.Allow()
-		if err != nil {
-			ctx.AbortWithError(consts.StatusTooManyRequests, err)
-			ctx.String(consts.StatusTooManyRequests, ctx.Errors.String())
-		} else {
-			ctx.Next(c)
-			done()
+	if NewOption(opts...).KeyFunc != nil {
+		return KeyedLimit(func() Limiter { return NewLimiter(opts...) }, opts...)
+	}
+	return LimitHandler(NewLimiter(opts...))
+}
+
+// ConcurrencyLimit adjusts the concurrency limit by the observed RTT using algo.
+// With WithKeyFunc, each key gets its own limiter driven by a fresh copy of Vegas, Gradient2 and AIMD,
+// while the limiters of other algorithms share algo under a lock, use ConcurrencyLimitFunc to give each key its own.
+func ConcurrencyLimit(algo LimitAlgorithm, opts ...Option) app.HandlerFunc {
+	if NewOption(opts...).KeyFunc != nil {
+		if f, ok := algo.(freshAlgorithm); ok {
+			return ConcurrencyLimitFunc(f.fresh, opts...)
 		}
+		algo = &lockedAlgorithm{algo: algo}
+	}
+	return ConcurrencyLimitFunc(func() LimitAlgorithm { return algo }, opts...)
+}
+
+// ConcurrencyLimitFunc is ConcurrencyLimit with the algorithm returned by newAlgo,
+// which is called once for each key with WithKeyFunc, e.g. for user-defined LimitAlgorithm.
+func ConcurrencyLimitFunc(newAlgo func() LimitAlgorithm, opts ...Option) app.HandlerFunc {
+	return KeyedLimit(func() Limiter { return NewConcurrencyLimiter(newAlgo(), opts...) }, opts...)
+}
+
+// KeyedLimit limits requests by the key extracted with WithKeyFunc,
+// newLimiter is called once for each key, so each tenant gets its own limiter.
+// Keys are kept in a KeyStore bounded by WithMaxKeys and WithKeyTTL, whose janitor runs as long as the process.
+// It panics if WithCluster is set with WithKeyFunc, since the limiters of all keys would publish as one instance.
+func KeyedLimit(newLimiter func() Limiter, opts ...Option) app.HandlerFunc {
+	opt := NewOption(opts...)
+	if opt.KeyFunc == nil {
+		return LimitHandler(newLimiter())
+	}
+	if opt.ClusterStore != nil {
+		panic("WithCluster can't be used with WithKeyFunc")
+	}
+	store := NewKeyStore(newLimiter, opts...)
+	store.Start()
+	return KeyedLimitHandler(store, opt.KeyFunc)
+}
+
+// KeyedLimitHandler returns the middleware using an existing KeyStore,
+// so that the lifecycle and stats of the store can be controlled by the caller.
+func KeyedLimitHandler(store *KeyStore, keyFunc func(c context.Context, ctx *app.RequestContext) string) app.HandlerFunc {
+	return func(c context.Context, ctx *app.RequestContext) {
+		serveLimited(c, ctx, store.Get(keyFunc(c, ctx)))
+	}
+}
+
+// LimitHandler returns the middleware using an existing limiter,
+// so that the limiter can be shared with other middlewares, e.g. the kitex one.
+func LimitHandler(limiter Limiter) app.HandlerFunc {
+	return func(c context.Context, ctx *app.RequestContext) {
+		serveLimited(c, ctx, limiter)
+	}
+}
+
+// serveLimited serves the request if limiter allows it, or aborts it with 429
+func serveLimited(c context.Context, ctx *app.RequestContext, limiter Limiter) {
+	done, err := limiter.Allow()
+	if err != nil {
+		ctx.AbortWithError(consts.StatusTooManyRequests, err)
+		ctx.String(consts.StatusTooManyRequests, ctx.Errors.String())
+	} else {
+		ctx.Next(c)
+		done()
 	}
 }
@@ -19,6 +19,8 @@ package limiter
 import (
 	"errors"
 	"math"
+	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -31,6 +33,9 @@ var (
 	gCPU     int64
 	gStat    linux.CPUStat
 	ErrLimit = "Hertz Adaptive Limit"
+
+	// ErrLimitExceeded is returned by every limiter when a request is rejected
+	ErrLimitExceeded = errors.New(ErrLimit)
 )
 
 type (
@@ -64,13 +69,41 @@ func calcCoreUsage(curr, prev linux.CPUStat) float64 {
 	return CPU_Percentage
 }
 
+// samplingConfig is the config of the cpu sampler shared by all limiters
+type samplingConfig struct {
+	interval time.Duration
+	decay    float64
+}
+
+// gSampling is the current *samplingConfig of cpuProc
+var gSampling atomic.Value
+
 func init() {
+	gSampling.Store(&samplingConfig{interval: opt.SamplingTime, decay: opt.Decay})
 	go cpuProc()
+	go systemProc()
+}
+
+// setSampling applies SamplingTime and Decay of o to the shared cpu sampler if they were set explicitly,
+// so the last limiter created or updated with them wins.
+func setSampling(o options) {
+	if !o.samplingTimeSet && !o.decaySet {
+		return
+	}
+	conf := *gSampling.Load().(*samplingConfig)
+	if o.samplingTimeSet && o.SamplingTime > 0 {
+		conf.interval = o.SamplingTime
+	}
+	if o.decaySet && o.Decay >= 0 && o.Decay < 1 {
+		conf.decay = o.Decay
+	}
+	gSampling.Store(&conf)
 }
 
 // cpuProc  CPU load correction by EMA algorithm
 func cpuProc() {
-	ticker := time.NewTicker(opt.SamplingTime) // same to cpu sample rate
+	conf := gSampling.Load().(*samplingConfig)
+	ticker := time.NewTicker(conf.interval) // same to cpu sample rate
 	defer func() {
 		ticker.Stop()
 		if err := recover(); err != nil {
@@ -80,15 +113,40 @@ func cpuProc() {
 
 	// EMA algorithm: https://blog.csdn.net/m0_38106113/article/details/81542863
 	for range ticker.C {
+		conf = reloadSampling(ticker, conf)
 		preState := gStat
 		curState := getCpuLoad()
 		usage := calcCoreUsage(preState, curState)
 		prevCPU := atomic.LoadInt64(&gCPU)
-		curCPU := int64(float64(prevCPU)*opt.Decay + float64(usage*10)*(1.0-opt.Decay))
+		curCPU := int64(float64(prevCPU)*conf.decay + float64(usage*10)*(1.0-conf.decay))
 		atomic.StoreInt64(&gCPU, curCPU)
 	}
 }
 
+// systemProc samples memory usage, load and scheduling on its own ticker,
+// so they are sampled even if /proc/stat is unreadable or the limiters are WithoutCPU.
+func systemProc() {
+	conf := gSampling.Load().(*samplingConfig)
+	ticker := time.NewTicker(conf.interval)
+	defer ticker.Stop()
+	sched := newSchedSampler()
+	for range ticker.C {
+		conf = reloadSampling(ticker, conf)
+		memProc()
+		loadProc()
+		sched.proc()
+	}
+}
+
+// reloadSampling returns the current sampling config, ticker is reset if the interval changed since conf
+func reloadSampling(ticker *time.Ticker, conf *samplingConfig) *samplingConfig {
+	c := gSampling.Load().(*samplingConfig)
+	if c.interval != conf.interval {
+		ticker.Reset(c.interval)
+	}
+	return c
+}
+
 // counterCache is used to cache maxPASS and minRt result.
 type counterCache struct {
 	val  int64
@@ -98,40 +156,156 @@ type counterCache struct {
 // BBR implements bbr-like limiter.It is inspired by sentinel.
 // https://github.com/alibaba/Sentinel/wiki/%E7%B3%BB%E7%BB%9F%E8%87%AA%E9%80%82%E5%BA%94%E9%99%90%E6%B5%81
 type BBR struct {
-	cpu             cpuGetter
-	passStat        *utils.RollingWindow // request succeeded
-	rtStat          *utils.RollingWindow // time consume
-	inFlight        int64                // Number of requests being processed
-	bucketPerSecond int64
-	bucketDuration  time.Duration
+	cpu      cpuGetter
+	memory   cpuGetter
+	load     func() float64
+	passStat utils.Window // request succeeded
+	rtStat   utils.Window // time consume
+	inFlight int64        // Number of requests being processed
+	clock    utils.Clock
+	random   func() float64
+	created  time.Time
+
+	// schedLatency returns the p99 scheduling latency in nanoseconds
+	schedLatency cpuGetter
+	goroutines   cpuGetter
 
 	// prevDropTime defines previous start drop since initTime
 	prevDropTime atomic.Value
+	// recoverTime defines the time cooling down started
+	recoverTime  atomic.Value
 	maxPASSCache atomic.Value
 	minRtCache   atomic.Value
+	avgRtCache   atomic.Value
+	qpsCache     atomic.Value
+	// cluster is the latest *clusterEstimate if WithCluster is set
+	cluster   atomic.Value
+	closeOnce sync.Once
+	stop      chan struct{}
 
-	opts options
+	// conf is the current *bbrConfig, swapped by Update
+	conf       atomic.Value
+	updateLock sync.Mutex
 }
 
-func NewLimiter(opts ...Option) *BBR {
-	opt := NewOption(opts...)
-	bucketDuration := opt.Window / time.Duration(opt.Bucket)
-	// 10s / 100  = 100ms
-	passStat := utils.NewRollingWindow(opt.Bucket, bucketDuration, utils.IgnoreCurrentBucket())
-	rtStat := utils.NewRollingWindow(opt.Bucket, bucketDuration, utils.IgnoreCurrentBucket())
+// bbrConfig is the immutable snapshot of options and the derived bucket layout
+type bbrConfig struct {
+	opts            options
+	bucketPerSecond int64
+	bucketDuration  time.Duration
+	// rtPerSecond is the number of RTUnit in a second
+	rtPerSecond float64
+}
 
-	limiter := &BBR{
+func newBBRConfig(opt options) *bbrConfig {
+	if opt.RTUnit <= 0 {
+		opt.RTUnit = time.Millisecond
+	}
+	// 10s / 100  = 100ms
+	bucketDuration := opt.Window / time.Duration(opt.Bucket)
+	return &bbrConfig{
 		opts:            opt,
-		passStat:        passStat,
-		rtStat:          rtStat,
 		bucketDuration:  bucketDuration,
 		bucketPerSecond: int64(time.Second / bucketDuration),
-		cpu:             func() int64 { return atomic.LoadInt64(&gCPU) },
+		rtPerSecond:     float64(time.Second) / float64(opt.RTUnit),
+	}
+}
+
+func NewLimiter(opts ...Option) *BBR {
+	opt := NewOption(opts...)
+	conf := newBBRConfig(opt)
+	passStat := newWindow(opt, conf.bucketDuration, utils.IgnoreCurrentBucket(), utils.WithClock(opt.Clock))
+	rtOpts := []utils.RollingWindowOption{utils.IgnoreCurrentBucket(), utils.WithClock(opt.Clock)}
+	if opt.RTQuantile > 0 {
+		rtOpts = append(rtOpts, utils.WithHistogram())
+	}
+	rtStat := newWindow(opt, conf.bucketDuration, rtOpts...)
+
+	limiter := &BBR{
+		passStat: passStat,
+		rtStat:   rtStat,
+		cpu:      func() int64 { return atomic.LoadInt64(&gCPU) },
+		memory:   func() int64 { return atomic.LoadInt64(&gMem) },
+		load:     getLoad,
+		clock:    opt.Clock,
+		random:   rand.Float64,
+		created:  opt.Clock.Now(),
+		stop:     make(chan struct{}),
+
+		schedLatency: func() int64 { return atomic.LoadInt64(&gSchedLatency) },
+		goroutines:   func() int64 { return atomic.LoadInt64(&gGoroutines) },
+	}
+	limiter.conf.Store(conf)
+	setSampling(opt)
+	if opt.ClusterStore != nil {
+		go limiter.clusterProc()
 	}
 
 	return limiter
 }
 
+// newWindow returns a ShardedRollingWindow if WithShards is set, or a RollingWindow
+func newWindow(opt options, bucketDuration time.Duration, opts ...utils.RollingWindowOption) utils.Window {
+	if opt.Shards > 1 {
+		return utils.NewShardedRollingWindow(opt.Shards, opt.Bucket, bucketDuration, opts...)
+	}
+	return utils.NewRollingWindow(opt.Bucket, bucketDuration, opts...)
+}
+
+// config returns the current config
+func (l *BBR) config() *bbrConfig {
+	return l.conf.Load().(*bbrConfig)
+}
+
+// Update applies opts on the current options of a live limiter, e.g. to tune CPUThreshold during an incident.
+// When Window or Bucket changes, the existing buckets are merged or split into the new layout, so the history is kept.
+// SamplingTime and Decay configure the cpu sampler shared by all limiters, while WithCluster, WithShards, WithClock
+// and WithRTUnit can't be updated. Invalid options, e.g. WithBucket(0), are rejected and the limiter keeps its config.
+func (l *BBR) Update(opts ...Option) error {
+	l.updateLock.Lock()
+	defer l.updateLock.Unlock()
+	prev := l.config()
+	opt := prev.opts
+	// only the sampling set by this update is applied, not the one this limiter was created with
+	opt.samplingTimeSet, opt.decaySet = false, false
+	for _, apply := range opts {
+		apply(&opt)
+	}
+	if err := opt.validate(); err != nil {
+		return err
+	}
+	opt.ClusterStore, opt.ClusterInstance = prev.opts.ClusterStore, prev.opts.ClusterInstance
+	opt.Shards, opt.Clock, opt.RTUnit = prev.opts.Shards, prev.opts.Clock, prev.opts.RTUnit
+	conf := newBBRConfig(opt)
+	setSampling(opt)
+	if opt.RTQuantile > 0 {
+		l.rtStat.EnableHistogram()
+	} else if prev.opts.RTQuantile > 0 {
+		l.rtStat.DisableHistogram()
+	}
+	if opt.RTQuantile != prev.opts.RTQuantile {
+		l.minRtCache.Store(&counterCache{})
+	}
+	if opt.Bucket != prev.opts.Bucket || conf.bucketDuration != prev.bucketDuration {
+		l.passStat.Resize(opt.Bucket, conf.bucketDuration)
+		l.rtStat.Resize(opt.Bucket, conf.bucketDuration)
+		// invalidate caches computed by the previous layout
+		l.maxPASSCache.Store(&counterCache{})
+		l.minRtCache.Store(&counterCache{})
+		l.avgRtCache.Store(&counterCache{})
+		l.qpsCache.Store(&counterCache{})
+	}
+	l.conf.Store(conf)
+	return nil
+}
+
+// Close stops publishing to the cluster if WithCluster is set
+func (l *BBR) Close() {
+	l.closeOnce.Do(func() {
+		close(l.stop)
+	})
+}
+
 // maxPass maximum number of requests in a single sampling window
 func (l *BBR) maxPass() int64 {
 	passCache := l.maxPASSCache.Load()
@@ -151,21 +325,22 @@ func (l *BBR) maxPass() int64 {
 	}
 	l.maxPASSCache.Store(&counterCache{
 		val:  int64(rawMaxPass),
-		time: time.Now(),
+		time: l.clock.Now(),
 	})
 	return int64(rawMaxPass)
 }
 
 // timespan returns the passed bucket count
 func (l *BBR) timespan(lastTime time.Time) int {
-	v := int(time.Since(lastTime) / l.bucketDuration)
+	conf := l.config()
+	v := int(l.clock.Now().Sub(lastTime) / conf.bucketDuration)
 	if v > -1 {
 		return v
 	}
-	return l.opts.Bucket
+	return conf.opts.Bucket
 }
 
-// minRT minimum response time
+// minRT minimum response time in RTUnit
 func (l *BBR) minRT() int64 {
 	rtCache := l.minRtCache.Load()
 	if rtCache != nil {
@@ -175,76 +350,179 @@ func (l *BBR) minRT() int64 {
 		}
 	}
 	// Go to the nearest response time within 1s
-	var rawMinRT float64 = 1 << 31
+	var rawMinRT float64 = 1
+	var found bool
+	q := l.config().opts.RTQuantile
 	l.rtStat.Reduce(func(b *utils.Bucket) {
 		if b.Count <= 0 {
 			return
 		}
-		if rawMinRT > math.Ceil(b.Sum/float64(b.Count)) {
-			rawMinRT = math.Ceil(b.Sum / float64(b.Count))
+		rt := b.Sum / float64(b.Count)
+		// buckets before the histogram is enabled by Update fall back to the mean
+		if q > 0 && b.Hist != nil && b.Hist.Count() > 0 {
+			rt = b.Hist.Quantile(q)
+		}
+		if !found || rawMinRT > math.Ceil(rt) {
+			rawMinRT = math.Ceil(rt)
+			found = true
 		}
 	})
-	if rawMinRT == 1<<31 {
-		rawMinRT = 1
-	}
 	l.minRtCache.Store(&counterCache{
 		val:  int64(rawMinRT),
-		time: time.Now(),
+		time: l.clock.Now(),
 	})
 	return int64(rawMinRT)
 }
 
-// maxInFlight calculating the load
+// Latency returns the q-quantile response time in the window, e.g. 0.99 for p99.
+// It returns 0 unless WithRTQuantile is set.
+func (l *BBR) Latency(q float64) time.Duration {
+	return time.Duration(l.rtStat.Quantile(q) * float64(l.config().opts.RTUnit))
+}
+
+// maxInFlight calculating the load, blended with the cluster estimate if WithCluster is set,
+// and at least the seeded capacity while warming up.
 func (l *BBR) maxInFlight() int64 {
-	return int64(math.Ceil(float64(l.maxPass()*l.minRT()*l.bucketPerSecond) / 1000.0))
+	conf := l.config()
+	maxPass, minRT := float64(l.maxPass()), float64(l.minRT())
+	if c, _ := l.cluster.Load().(*clusterEstimate); c != nil {
+		w := conf.opts.ClusterWeight
+		maxPass = maxPass*(1-w) + c.maxPass*w
+		minRT = minRT*(1-w) + c.minRT/float64(conf.opts.RTUnit)*w
+	}
+	return l.warmUpCapacity(conf.opts, int64(math.Ceil(maxPass*minRT*float64(conf.bucketPerSecond)/conf.rtPerSecond)))
+}
+
+// overloaded reports whether the OverloadDetector or any system rule reports overloaded,
+// without OverloadDetector, CPU load, memory usage, scheduling latency and goroutine count are checked instead.
+// Once dropping, a HysteresisDetector is asked whether it's still overloaded, e.g. by CPUExitThreshold.
+func (l *BBR) overloaded() bool {
+	opts := l.config().opts
+	detector := l.detector(opts)
+	if l.phase() != phaseNormal {
+		if stillOverloaded(detector) {
+			return true
+		}
+	} else if detector.Overloaded() {
+		return true
+	}
+	return l.systemOverloaded(opts)
+}
+
+// detector returns the OverloadDetector of opts, or the built-in one
+func (l *BBR) detector(opts options) OverloadDetector {
+	if opts.OverloadDetector != nil {
+		return opts.OverloadDetector
+	}
+	return systemDetector{l: l}
 }
 
-// shouldDrop (CPU load > 80% || (now - prevDrop) < 1s) and (MaxPass * MinRT * windows) / 1000 < InFlight
+// dropPhase is the phase of the drop state machine:
+//
+//	normal --(overloaded and inflight exceeds the capacity)--> dropping
+//	dropping --(not overloaded)--> coolDown
+//	coolDown --(overloaded)--> dropping
+//	coolDown --(CoolDown since recovered and MinDropDuration since dropping)--> normal
+//
+// Requests are dropped when the inflight count exceeds the capacity, except in the normal phase without overload.
+type dropPhase int
+
+const (
+	phaseNormal dropPhase = iota
+	phaseDropping
+	phaseCoolDown
+)
+
+// phase returns the current phase, prevDropTime is set since dropping and recoverTime since cooling down
+func (l *BBR) phase() dropPhase {
+	if prevDropTime, _ := l.prevDropTime.Load().(time.Duration); prevDropTime == 0 {
+		return phaseNormal
+	}
+	if recoverTime, _ := l.recoverTime.Load().(time.Duration); recoverTime == 0 {
+		return phaseDropping
+	}
+	return phaseCoolDown
+}
+
+// exceeded reports whether the inflight count exceeds (MaxPass * MinRT * windows) / 1000,
+// with DropSpan the request is dropped by the probability of dropProbability.
+func (l *BBR) exceeded() bool {
+	inFlight := atomic.LoadInt64(&l.inFlight)
+	if inFlight <= 1 {
+		return false
+	}
+	maxInFlight := l.maxInFlight()
+	if inFlight <= maxInFlight {
+		return false
+	}
+	opts := l.config().opts
+	if opts.DropSpan <= 0 {
+		return true
+	}
+	return l.random() < l.dropProbability(opts, inFlight, maxInFlight)
+}
+
+// dropProbability grows linearly to 1 when inflight exceeds the capacity by DropSpan,
+// and the pressure of a PressureDetector, e.g. the CPU load over CPUThreshold, raises it further towards 1.
+func (l *BBR) dropProbability(opts options, inFlight, maxInFlight int64) float64 {
+	p := math.Min(float64(inFlight-maxInFlight)/(float64(maxInFlight)*opts.DropSpan), 1)
+	return p + (1-p)*pressure(l.detector(opts))
+}
+
+// shouldDrop steps the drop state machine, see dropPhase
 func (l *BBR) shouldDrop() bool {
-	now := time.Duration(time.Now().UnixNano())
-	if l.cpu() < l.opts.CPUThreshold {
-		// current cpu payload below the threshold
-		prevDropTime, _ := l.prevDropTime.Load().(time.Duration)
-		if prevDropTime == 0 {
-			// haven't start drop,
-			// accept current request
-			return false
+	if l.admitAll(l.config().opts) {
+		return false
+	}
+	now := time.Duration(l.clock.Now().UnixNano())
+	phase := l.phase()
+	if l.overloaded() {
+		if phase == phaseCoolDown {
+			// overloaded again
+			l.recoverTime.Store(time.Duration(0))
 		}
-		if time.Duration(now-prevDropTime) <= time.Second {
-			// just start drop one second ago,
-			// check current inflight count
-			inFlight := atomic.LoadInt64(&l.inFlight)
-			return inFlight > 1 && inFlight > l.maxInFlight()
+		drop := l.exceeded()
+		if drop && phase == phaseNormal {
+			// store start drop time
+			l.prevDropTime.Store(now)
 		}
-		l.prevDropTime.Store(time.Duration(0))
+		return drop
+	}
+	// not overloaded
+	switch phase {
+	case phaseNormal:
+		// haven't start drop,
+		// accept current request
 		return false
+	case phaseDropping:
+		// just recovered, start cooling down
+		l.recoverTime.Store(now)
 	}
-	// current cpu payload exceeds the threshold
-	inFlight := atomic.LoadInt64(&l.inFlight)
-	drop := inFlight > 1 && inFlight > l.maxInFlight()
-	if drop {
-		prevDrop, _ := l.prevDropTime.Load().(time.Duration)
-		if prevDrop != 0 {
-			// already started drop, return directly
-			return drop
-		}
-		// store start drop time
-		l.prevDropTime.Store(now)
+	opts := l.config().opts
+	prevDropTime, _ := l.prevDropTime.Load().(time.Duration)
+	recoverTime, _ := l.recoverTime.Load().(time.Duration)
+	if now-recoverTime < opts.CoolDown || now-prevDropTime <= opts.MinDropDuration {
+		// still cooling down,
+		// check current inflight count
+		return l.exceeded()
 	}
-	return drop
+	l.prevDropTime.Store(time.Duration(0))
+	l.recoverTime.Store(time.Duration(0))
+	return false
 }
 
 // Allow determines the alarm triggering conditions, record the interface time consumption and QPS
 func (l *BBR) Allow() (func(), error) {
 	if l.shouldDrop() {
-		return nil, errors.New(ErrLimit)
+		return nil, ErrLimitExceeded
 	}
 	atomic.AddInt64(&l.inFlight, 1)
-	start := time.Now().UnixNano()
+	start := l.clock.Now().UnixNano()
+	rtUnit := l.config().opts.RTUnit
 	// DoneFunc record time-consuming
 	return func() {
-		rt := (time.Now().UnixNano() - start) / int64(time.Millisecond)
-		l.rtStat.Add(float64(rt))
+		rt := float64(l.clock.Now().UnixNano()-start) / float64(rtUnit)
+		l.rtStat.Add(rt)
 		atomic.AddInt64(&l.inFlight, -1)
 		l.passStat.Add(1)
 	}, nil
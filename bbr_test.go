@@ -87,11 +87,12 @@ func TestBBR(t *testing.T) {
 }
 
 func TestBBRmaxPass(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
 	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
-	bbr := NewLimiter(optsForTest...)
+	bbr := NewLimiter(append(optsForTest, WithClock(clock))...)
 	for i := 1; i <= 10; i++ {
 		bbr.passStat.Add(float64(i * 100))
-		time.Sleep(bucketDuration)
+		clock.Advance(bucketDuration)
 	}
 	assert.Equal(t, int64(1000), bbr.maxPass())
 	// // default max pass is equal to 1.
@@ -100,32 +101,34 @@ func TestBBRmaxPass(t *testing.T) {
 }
 
 func TestBBRmaxPassWithCache(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
 	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
-	bbr := NewLimiter(optsForTest...)
+	bbr := NewLimiter(append(optsForTest, WithClock(clock))...)
 	// witch cache, value of latest bucket is not counted instantly.
 	// after a bucket duration time, this bucket will be fully counted.
 	bbr.passStat.Add(float64(50))
-	time.Sleep(bucketDuration / 2)
+	clock.Advance(bucketDuration / 2)
 	assert.Equal(t, int64(1), bbr.maxPass())
 
 	bbr.passStat.Add(float64(50))
-	time.Sleep(bucketDuration / 2)
+	clock.Advance(bucketDuration / 2)
 	assert.Equal(t, int64(1), bbr.maxPass())
 
 	bbr.passStat.Add(float64(1))
-	time.Sleep(bucketDuration)
+	clock.Advance(bucketDuration)
 	assert.Equal(t, int64(100), bbr.maxPass())
 }
 
 func TestBBRMinRt(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
 	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
-	bbr := NewLimiter(optsForTest...)
+	bbr := NewLimiter(append(optsForTest, WithClock(clock))...)
 	for i := 0; i < 10; i++ {
 		for j := i*10 + 1; j <= i*10+10; j++ {
 			bbr.rtStat.Add(float64(j))
 		}
 		if i != 9 {
-			time.Sleep(bucketDuration)
+			clock.Advance(bucketDuration)
 		}
 	}
 	assert.Equal(t, int64(6), bbr.minRT())
@@ -137,38 +140,40 @@ func TestBBRMinRt(t *testing.T) {
 }
 
 func TestBBRMinRtWithCache(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
 	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
-	bbr := NewLimiter(optsForTest...)
+	bbr := NewLimiter(append(optsForTest, WithClock(clock))...)
 	for i := 0; i < 10; i++ {
 		for j := i*10 + 1; j <= i*10+5; j++ {
 			bbr.rtStat.Add(float64(j))
 		}
 		if i != 9 {
-			time.Sleep(bucketDuration / 2)
+			clock.Advance(bucketDuration / 2)
 		}
 		_ = bbr.minRT()
 		for j := i*10 + 6; j <= i*10+10; j++ {
 			bbr.rtStat.Add(float64(j))
 		}
 		if i != 9 {
-			time.Sleep(bucketDuration / 2)
+			clock.Advance(bucketDuration / 2)
 		}
 	}
 	assert.Equal(t, int64(6), bbr.minRT())
 }
 
 func TestBBRMaxQps(t *testing.T) {
-	bbr := NewLimiter(optsForTest...)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := NewLimiter(append(optsForTest, WithClock(clock))...)
 	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
-	passStat := utils.NewRollingWindow(10, bucketDuration, utils.IgnoreCurrentBucket())
-	rtStat := utils.NewRollingWindow(10, bucketDuration, utils.IgnoreCurrentBucket())
+	passStat := utils.NewRollingWindow(10, bucketDuration, utils.IgnoreCurrentBucket(), utils.WithClock(clock))
+	rtStat := utils.NewRollingWindow(10, bucketDuration, utils.IgnoreCurrentBucket(), utils.WithClock(clock))
 	for i := 0; i < 10; i++ {
 		passStat.Add(float64((i + 2) * 100))
 		for j := i*10 + 1; j <= i*10+10; j++ {
 			rtStat.Add(float64(j))
 		}
 		if i != 9 {
-			time.Sleep(bucketDuration)
+			clock.Advance(bucketDuration)
 		}
 	}
 	bbr.passStat = passStat
@@ -178,20 +183,21 @@ func TestBBRMaxQps(t *testing.T) {
 
 func TestBBRShouldDrop(t *testing.T) {
 	var cpu int64
-	bbr := NewLimiter(optsForTest...)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := NewLimiter(append(optsForTest, WithClock(clock))...)
 	bbr.cpu = func() int64 {
 		return cpu
 	}
 	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
-	passStat := utils.NewRollingWindow(10, bucketDuration)
-	rtStat := utils.NewRollingWindow(10, bucketDuration)
+	passStat := utils.NewRollingWindow(10, bucketDuration, utils.WithClock(clock))
+	rtStat := utils.NewRollingWindow(10, bucketDuration, utils.WithClock(clock))
 	for i := 0; i < 10; i++ {
 		passStat.Add(float64((i + 1) * 100))
 		for j := i*10 + 1; j <= i*10+10; j++ {
 			rtStat.Add(float64(j))
 		}
 		if i != 9 {
-			time.Sleep(bucketDuration)
+			clock.Advance(bucketDuration)
 		}
 	}
 	bbr.passStat = passStat
@@ -212,7 +218,7 @@ func TestBBRShouldDrop(t *testing.T) {
 	assert.Equal(t, true, bbr.shouldDrop())
 
 	// cpu < 800, inflight > maxQps
-	time.Sleep(2 * time.Second)
+	clock.Advance(2 * time.Second)
 	cpu = 700
 	bbr.inFlight = 80
 	assert.Equal(t, false, bbr.shouldDrop())
@@ -297,3 +303,401 @@ func BenchmarkBBRShouldDropUnderUnstableLoad(b *testing.B) {
 		}
 	}
 }
+
+func TestBBRUpdate(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	bbr := NewLimiter(append(optsForTest, WithClock(clock))...)
+	bbr.cpu = func() int64 {
+		return 850
+	}
+	bbr.inFlight = 80
+	for i := 0; i < 4; i++ {
+		bbr.passStat.Add(100)
+		bbr.rtStat.Add(50)
+		clock.Advance(bucketDuration)
+	}
+	assert.Equal(t, int64(100), bbr.maxPass())
+	assert.Equal(t, true, bbr.shouldDrop())
+
+	// thresholds are swapped without losing the history
+	assert.Nil(t, bbr.Update(WithCPUThreshold(900)))
+	bbr.prevDropTime.Store(time.Duration(0))
+	assert.Equal(t, false, bbr.shouldDrop())
+	assert.Equal(t, int64(100), bbr.maxPass())
+
+	// twice bucket duration, every two buckets are merged
+	assert.Nil(t, bbr.Update(WithBucket(bucketNumTest/2)))
+	assert.Equal(t, int64(200), bbr.maxPass())
+	assert.Equal(t, int64(50), bbr.minRT())
+	assert.Equal(t, int64(900), bbr.config().opts.CPUThreshold)
+}
+
+func TestBBRUpdateFiner(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	bbr := NewLimiter(append(optsForTest, WithClock(clock))...)
+	for i := 0; i < bucketNumTest; i++ {
+		for j := 0; j < 100; j++ {
+			bbr.passStat.Add(1)
+			bbr.rtStat.Add(10)
+		}
+		clock.Advance(bucketDuration)
+	}
+	// 100 * 10 * 10 / 1000
+	assert.Equal(t, int64(10), bbr.maxInFlight())
+
+	// half bucket duration, every bucket is split into two
+	assert.Nil(t, bbr.Update(WithBucket(bucketNumTest*2)))
+	assert.Equal(t, int64(50), bbr.maxPass())
+	assert.Equal(t, int64(10), bbr.minRT())
+	assert.Equal(t, int64(10), bbr.maxInFlight())
+}
+
+func TestBBRUpdateInvalid(t *testing.T) {
+	bbr := NewLimiter(optsForTest...)
+	prev := bbr.config()
+	for _, opt := range []Option{
+		WithBucket(0),
+		WithBucket(-1),
+		WithWindow(0),
+		WithWindow(time.Duration(bucketNumTest - 1)),
+		WithSamplingTime(0),
+		WithDecay(1),
+		WithDecay(-0.1),
+		WithRTUnit(0),
+		WithRTUnit(2 * time.Second),
+	} {
+		assert.NotNil(t, bbr.Update(WithCPUThreshold(900), opt))
+		assert.Same(t, prev, bbr.config())
+	}
+}
+
+func TestBBRMinRtWithQuantile(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	bbr := NewLimiter(append(optsForTest, WithClock(clock), WithRTQuantile(0.5))...)
+	for i := 0; i < 2; i++ {
+		// a few slow requests skew the mean
+		for j := 0; j < 9; j++ {
+			bbr.rtStat.Add(10)
+		}
+		bbr.rtStat.Add(1000)
+		clock.Advance(bucketDuration)
+	}
+	assert.Equal(t, int64(10), bbr.minRT())
+	assert.Equal(t, 10*time.Millisecond, bbr.Latency(0.5))
+	assert.Equal(t, 1000*time.Millisecond, bbr.Latency(1))
+
+	// the mean without quantile, the histograms are released
+	assert.Nil(t, bbr.Update(WithRTQuantile(0)))
+	assert.Equal(t, int64(109), bbr.minRT())
+	bbr.rtStat.Reduce(func(b *utils.Bucket) {
+		assert.Nil(t, b.Hist)
+	})
+}
+
+func TestBBRWithShards(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	bbr := NewLimiter(append(optsForTest, WithClock(clock), WithShards(4))...)
+	for i := 1; i <= 4; i++ {
+		for j := 0; j < 100; j++ {
+			bbr.passStat.Add(1)
+			bbr.rtStat.Add(float64(i * 10))
+		}
+		clock.Advance(bucketDuration)
+	}
+	assert.Equal(t, int64(100), bbr.maxPass())
+	assert.Equal(t, int64(10), bbr.minRT())
+
+	// shards are kept by Update
+	assert.Nil(t, bbr.Update(WithBucket(bucketNumTest/2), WithShards(1)))
+	assert.Equal(t, 4, bbr.config().opts.Shards)
+	assert.Equal(t, int64(200), bbr.maxPass())
+}
+
+func benchmarkBBRAllowParallel(b *testing.B, opts ...Option) {
+	bbr := NewLimiter(append(optsForTest, opts...)...)
+	bbr.cpu = func() int64 {
+		return 500
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			done, err := bbr.Allow()
+			if err == nil {
+				done()
+			}
+		}
+	})
+}
+
+func BenchmarkBBRAllowParallel(b *testing.B) {
+	benchmarkBBRAllowParallel(b)
+}
+
+func BenchmarkBBRAllowParallelWithShards(b *testing.B) {
+	benchmarkBBRAllowParallel(b, WithShards(16))
+}
+
+func TestBBRWithClock(t *testing.T) {
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := NewLimiter(append(optsForTest, WithClock(clock))...)
+	for i := 0; i < 3; i++ {
+		done, err := bbr.Allow()
+		assert.Nil(t, err)
+		clock.Advance(20 * time.Millisecond)
+		done()
+	}
+	// the current bucket is ignored
+	assert.Equal(t, int64(1), bbr.maxPass())
+	clock.Advance(bucketDuration / 2)
+	// cached within the bucket
+	assert.Equal(t, int64(1), bbr.maxPass())
+	clock.Advance(bucketDuration / 2)
+	assert.Equal(t, int64(3), bbr.maxPass())
+	assert.Equal(t, int64(20), bbr.minRT())
+}
+
+// newDropTestLimiter returns a limiter driven by clock with one bucket of 10 passed requests of 10ms,
+// so its capacity is 1 while 80 requests are in flight, the CPU load is read from cpu.
+func newDropTestLimiter(clock *utils.FakeClock, cpu *int64, opts ...Option) *BBR {
+	bbr := NewLimiter(append(append(optsForTest, WithClock(clock)), opts...)...)
+	bbr.cpu = func() int64 {
+		return *cpu
+	}
+	bbr.passStat.Add(10)
+	bbr.rtStat.Add(10)
+	// the current bucket is ignored until it's passed
+	clock.Advance(windowSizeTest / time.Duration(bucketNumTest))
+	bbr.inFlight = 80
+	return bbr
+}
+
+func TestBBRDropPhase(t *testing.T) {
+	cpu := int64(900)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := newDropTestLimiter(clock, &cpu, WithCoolDown(3*time.Second))
+	assert.Equal(t, phaseNormal, bbr.phase())
+	assert.Equal(t, true, bbr.shouldDrop())
+	assert.Equal(t, phaseDropping, bbr.phase())
+
+	// recovered, cooling down for 3s since then
+	clock.Advance(5 * time.Second)
+	cpu = 500
+	assert.Equal(t, true, bbr.shouldDrop())
+	assert.Equal(t, phaseCoolDown, bbr.phase())
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, true, bbr.shouldDrop())
+
+	// overloaded again
+	cpu = 900
+	assert.Equal(t, true, bbr.shouldDrop())
+	assert.Equal(t, phaseDropping, bbr.phase())
+	cpu = 500
+	assert.Equal(t, true, bbr.shouldDrop())
+	clock.Advance(3*time.Second + time.Millisecond)
+	assert.Equal(t, false, bbr.shouldDrop())
+	assert.Equal(t, phaseNormal, bbr.phase())
+}
+
+func TestBBRMinDropDuration(t *testing.T) {
+	cpu := int64(900)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := newDropTestLimiter(clock, &cpu, WithCoolDown(0), WithMinDropDuration(10*time.Second))
+	assert.Equal(t, true, bbr.shouldDrop())
+	cpu = 500
+	clock.Advance(time.Second)
+	assert.Equal(t, true, bbr.shouldDrop())
+	clock.Advance(9 * time.Second)
+	assert.Equal(t, true, bbr.shouldDrop())
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, false, bbr.shouldDrop())
+}
+
+func TestBBRDropDefault(t *testing.T) {
+	cpu := int64(900)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := newDropTestLimiter(clock, &cpu)
+	assert.Equal(t, true, bbr.shouldDrop())
+	// recovered within 1s since dropping started, keep dropping until then
+	clock.Advance(500 * time.Millisecond)
+	cpu = 500
+	assert.Equal(t, true, bbr.shouldDrop())
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, true, bbr.shouldDrop())
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, false, bbr.shouldDrop())
+	assert.Equal(t, phaseNormal, bbr.phase())
+
+	// recovered after 1s of dropping, stop at once
+	cpu = 900
+	assert.Equal(t, true, bbr.shouldDrop())
+	clock.Advance(5 * time.Second)
+	cpu = 500
+	assert.Equal(t, false, bbr.shouldDrop())
+	assert.Equal(t, phaseNormal, bbr.phase())
+}
+
+func TestBBRCPUExitThreshold(t *testing.T) {
+	cpu := int64(790)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := newDropTestLimiter(clock, &cpu, WithCPUExitThreshold(700), WithCoolDown(0))
+	assert.Equal(t, false, bbr.shouldDrop())
+	cpu = 810
+	assert.Equal(t, true, bbr.shouldDrop())
+	// still overloaded above the exit threshold
+	cpu = 750
+	clock.Advance(time.Second)
+	assert.Equal(t, true, bbr.shouldDrop())
+	assert.Equal(t, phaseDropping, bbr.phase())
+	cpu = 690
+	assert.Equal(t, true, bbr.shouldDrop())
+	assert.Equal(t, phaseCoolDown, bbr.phase())
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, false, bbr.shouldDrop())
+	// back to the enter threshold
+	cpu = 790
+	assert.Equal(t, false, bbr.shouldDrop())
+}
+
+func TestBBRDropProbability(t *testing.T) {
+	cpu := int64(800)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := newDropTestLimiter(clock, &cpu, WithDropSpan(0.5))
+	opts := bbr.config().opts
+	assert.Equal(t, 0.0, bbr.dropProbability(opts, 100, 100))
+	assert.Equal(t, 0.5, bbr.dropProbability(opts, 125, 100))
+	assert.Equal(t, 1.0, bbr.dropProbability(opts, 200, 100))
+	// cpu over threshold raises the probability
+	cpu = 900
+	assert.Equal(t, 0.75, bbr.dropProbability(opts, 125, 100))
+	cpu = 1000
+	assert.Equal(t, 1.0, bbr.dropProbability(opts, 101, 100))
+}
+
+func TestBBRShouldDropProbabilistically(t *testing.T) {
+	cpu := int64(800)
+	random := 0.0
+	clock := utils.NewFakeClock(time.Now())
+	bbr := newDropTestLimiter(clock, &cpu, WithDropSpan(1))
+	bbr.random = func() float64 {
+		return random
+	}
+	// capacity is 1
+	bbr.inFlight = 2
+	random = 0.4
+	assert.Equal(t, true, bbr.shouldDrop())
+	random = 0.99
+	bbr.inFlight = 1
+	assert.Equal(t, false, bbr.shouldDrop())
+
+	assert.Nil(t, bbr.Update(WithDropSpan(100)))
+	bbr.inFlight = 51
+	random = 0.6
+	assert.Equal(t, false, bbr.shouldDrop())
+	random = 0.4
+	assert.Equal(t, true, bbr.shouldDrop())
+}
+
+func TestBBRRTUnit(t *testing.T) {
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := NewLimiter(append(optsForTest, WithClock(clock), WithRTUnit(time.Microsecond), WithRTQuantile(0.5))...)
+	for i := 0; i < 100; i++ {
+		done, err := bbr.Allow()
+		assert.Nil(t, err)
+		clock.Advance(300 * time.Microsecond)
+		done()
+	}
+	clock.Advance(bucketDuration)
+	assert.Equal(t, int64(300), bbr.minRT())
+	assert.Equal(t, 300*time.Microsecond, bbr.Latency(0.5))
+	// 100 per bucket, 1000 per second, 300us each
+	assert.Equal(t, int64(1), bbr.maxInFlight())
+
+	assert.Nil(t, bbr.Update(WithAvgRTThreshold(time.Millisecond), WithRTUnit(time.Millisecond)))
+	assert.Equal(t, time.Microsecond, bbr.config().opts.RTUnit)
+	assert.Equal(t, false, bbr.systemOverloaded(bbr.config().opts))
+	assert.Nil(t, bbr.Update(WithAvgRTThreshold(200*time.Microsecond)))
+	assert.Equal(t, true, bbr.systemOverloaded(bbr.config().opts))
+
+	// restored in millisecond
+	data, err := bbr.Snapshot().MarshalBinary()
+	assert.Nil(t, err)
+	var s Snapshot
+	assert.Nil(t, s.UnmarshalBinary(data))
+	assert.Equal(t, time.Microsecond, s.RTUnit)
+	fresh := NewLimiter(append(optsForTest, WithClock(clock))...)
+	fresh.Restore(s)
+	var sum float64
+	fresh.rtStat.Reduce(func(b *utils.Bucket) {
+		sum += b.Sum
+	})
+	assert.InDelta(t, 30, sum, 1e-6)
+	// the floor of 1ms
+	assert.Equal(t, int64(1), fresh.minRT())
+}
+
+func TestBBRMinRTNanosecond(t *testing.T) {
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := NewLimiter(append(optsForTest, WithClock(clock), WithRTUnit(time.Nanosecond))...)
+	// no data
+	assert.Equal(t, int64(1), bbr.minRT())
+	bbr.rtStat.Add(float64(3 * time.Second))
+	clock.Advance(bucketDuration)
+	// over 1<<31 units
+	assert.Equal(t, int64(3*time.Second), bbr.minRT())
+}
+
+func TestBBRRestoreFiner(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	peer := NewLimiter(append(optsForTest, WithClock(clock), WithWindow(10*time.Second), WithBucket(10))...)
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 1000; j++ {
+			peer.passStat.Add(1)
+			peer.rtStat.Add(10)
+		}
+		clock.Advance(time.Second)
+	}
+	// 1000 * 10 * 1 / 1000
+	assert.Equal(t, int64(10), peer.maxInFlight())
+
+	// the 1s buckets are split over the 100ms buckets
+	fresh := NewLimiter(append(optsForTest, WithClock(clock))...)
+	fresh.Restore(peer.Snapshot())
+	assert.Equal(t, int64(100), fresh.maxPass())
+	assert.Equal(t, int64(10), fresh.minRT())
+	assert.Equal(t, int64(10), fresh.maxInFlight())
+}
+
+func TestBBRSampling(t *testing.T) {
+	prev := gSampling.Load()
+	defer gSampling.Store(prev)
+
+	// defaults don't override the shared sampler
+	gSampling.Store(&samplingConfig{interval: time.Second, decay: 0.5})
+	NewLimiter(optsForTest...)
+	assert.Equal(t, &samplingConfig{interval: time.Second, decay: 0.5}, gSampling.Load())
+
+	NewLimiter(append(optsForTest, WithDecay(0.9))...)
+	assert.Equal(t, &samplingConfig{interval: time.Second, decay: 0.9}, gSampling.Load())
+	bbr := NewLimiter(append(optsForTest, WithSamplingTime(200*time.Millisecond))...)
+	assert.Equal(t, &samplingConfig{interval: 200 * time.Millisecond, decay: 0.9}, gSampling.Load())
+	assert.Nil(t, bbr.Update(WithDecay(0.8)))
+	assert.Equal(t, &samplingConfig{interval: 200 * time.Millisecond, decay: 0.8}, gSampling.Load())
+
+	// explicit defaults are applied too
+	assert.Nil(t, bbr.Update(WithDecay(0.95), WithSamplingTime(500*time.Millisecond)))
+	assert.Equal(t, &samplingConfig{interval: 500 * time.Millisecond, decay: 0.95}, gSampling.Load())
+
+	// updating another option doesn't apply the sampling again
+	other := NewLimiter(append(optsForTest, WithDecay(0.5))...)
+	assert.Nil(t, bbr.Update(WithCPUThreshold(900)))
+	assert.Equal(t, &samplingConfig{interval: 500 * time.Millisecond, decay: 0.5}, gSampling.Load())
+	assert.Nil(t, other.Update(WithCPUThreshold(900)))
+	assert.Equal(t, &samplingConfig{interval: 500 * time.Millisecond, decay: 0.5}, gSampling.Load())
+}
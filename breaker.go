@@ -0,0 +1,233 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/cloudwego/hertz/pkg/protocol"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
+
+// ErrBreakerOpen is returned when the circuit breaker rejects the call,
+// errors.Is(ErrBreakerOpen, ErrLimitExceeded) holds like the rejections of other limiters.
+var ErrBreakerOpen error = breakerOpenError{}
+
+type breakerOpenError struct{}
+
+func (breakerOpenError) Error() string { return "Hertz Circuit Breaker Open" }
+
+func (breakerOpenError) Unwrap() error { return ErrLimitExceeded }
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int32
+
+const (
+	// StateClosed lets all calls pass and records their results
+	StateClosed BreakerState = iota
+	// StateOpen rejects all calls until the open duration elapsed
+	StateOpen
+	// StateHalfOpen lets a limited number of probe calls pass
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker is a circuit breaker opened by the error rate or the slow call rate,
+// which are computed over the rolling window.
+type Breaker struct {
+	mu         sync.Mutex
+	state      BreakerState
+	generation int64 // increased on every state change, results of previous generation are ignored
+	openedAt   time.Time
+	errStat    *utils.RollingWindow // Sum is failed calls, Count is calls
+	slowStat   *utils.RollingWindow // Sum is slow calls, Count is calls
+	probes     int64                // permitted calls in half-open state
+	successes  int64                // succeeded calls in half-open state
+
+	bucketDuration time.Duration
+	opts           options
+}
+
+// NewBreaker returns a closed Breaker
+func NewBreaker(opts ...Option) *Breaker {
+	opt := NewOption(opts...)
+	b := &Breaker{
+		bucketDuration: opt.Window / time.Duration(opt.Bucket),
+		opts:           opt,
+	}
+	b.resetStat()
+	return b
+}
+
+func (b *Breaker) resetStat() {
+	b.errStat = utils.NewRollingWindow(b.opts.Bucket, b.bucketDuration, utils.WithClock(b.opts.Clock))
+	b.slowStat = utils.NewRollingWindow(b.opts.Bucket, b.bucketDuration, utils.WithClock(b.opts.Clock))
+}
+
+// State returns the current state
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && b.opts.Clock.Now().Sub(b.openedAt) >= b.opts.OpenDuration {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Allow returns ErrBreakerOpen if the call is not permitted,
+// otherwise the returned func must be called with the call result once it's done.
+func (b *Breaker) Allow() (func(success bool), error) {
+	b.mu.Lock()
+	from := b.state
+	if b.state == StateOpen && b.opts.Clock.Now().Sub(b.openedAt) >= b.opts.OpenDuration {
+		b.setState(StateHalfOpen)
+	}
+	var err error
+	switch b.state {
+	case StateOpen:
+		err = ErrBreakerOpen
+	case StateHalfOpen:
+		if b.probes >= b.opts.HalfOpenRequests {
+			err = ErrBreakerOpen
+		} else {
+			b.probes++
+		}
+	}
+	to, generation := b.state, b.generation
+	b.mu.Unlock()
+	b.notify(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	start := b.opts.Clock.Now()
+	return func(success bool) {
+		b.done(generation, success, b.opts.Clock.Now().Sub(start))
+	}, nil
+}
+
+// done records the call result and changes state accordingly
+func (b *Breaker) done(generation int64, success bool, rt time.Duration) {
+	slow := rt >= b.opts.SlowCallDuration
+	b.mu.Lock()
+	from := b.state
+	if generation == b.generation {
+		switch b.state {
+		case StateClosed:
+			b.errStat.Add(boolValue(!success))
+			b.slowStat.Add(boolValue(slow))
+			if b.shouldOpen() {
+				b.setState(StateOpen)
+			}
+		case StateHalfOpen:
+			if !success || slow {
+				b.setState(StateOpen)
+			} else if b.successes++; b.successes >= b.opts.HalfOpenRequests {
+				b.setState(StateClosed)
+			}
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+// shouldOpen reports whether the error rate or slow call rate reaches the threshold
+func (b *Breaker) shouldOpen() bool {
+	failed, calls := sumStat(b.errStat)
+	if calls < b.opts.MinRequests {
+		return false
+	}
+	slows, _ := sumStat(b.slowStat)
+	return failed/float64(calls) >= b.opts.ErrorRateThreshold ||
+		slows/float64(calls) >= b.opts.SlowCallRateThreshold
+}
+
+// setState must be called with lock held
+func (b *Breaker) setState(state BreakerState) {
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.successes = 0
+	switch state {
+	case StateOpen:
+		b.openedAt = b.opts.Clock.Now()
+	case StateClosed:
+		b.resetStat()
+	}
+}
+
+func (b *Breaker) notify(from, to BreakerState) {
+	if from != to && b.opts.OnStateChange != nil {
+		b.opts.OnStateChange(from, to)
+	}
+}
+
+func sumStat(rw *utils.RollingWindow) (sum float64, count int64) {
+	rw.Reduce(func(b *utils.Bucket) {
+		sum += b.Sum
+		count += b.Count
+	})
+	return
+}
+
+func boolValue(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// ClientBreaker is a client middleware stopping calls to a broken dependency,
+// transport errors, 429 and 5xx responses are counted as failed calls.
+func ClientBreaker(opts ...Option) client.Middleware {
+	return breakerMiddleware(NewBreaker(opts...))
+}
+
+func breakerMiddleware(b *Breaker) client.Middleware {
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			return roundTripBreaker(b, next, ctx, req, resp)
+		}
+	}
+}
+
+// roundTripBreaker calls next through b
+func roundTripBreaker(b *Breaker, next client.Endpoint, ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = next(ctx, req, resp)
+	done(err == nil && !isRejectedStatus(resp.StatusCode()))
+	return err
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/hertz-contrib/limiter/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func breakerCall(t *testing.T, b *Breaker, success bool) {
+	done, err := b.Allow()
+	assert.Nil(t, err)
+	done(success)
+}
+
+func TestBreakerErrorRate(t *testing.T) {
+	var changes []BreakerState
+	clock := utils.NewFakeClock(time.Now())
+	b := NewBreaker(append(optsForTest,
+		WithClock(clock),
+		WithMinRequests(10),
+		WithErrorRateThreshold(0.5),
+		WithOpenDuration(100*time.Millisecond),
+		WithHalfOpenRequests(2),
+		WithStateChangeHook(func(from, to BreakerState) {
+			changes = append(changes, to)
+		}))...)
+
+	for i := 0; i < 5; i++ {
+		breakerCall(t, b, true)
+	}
+	for i := 0; i < 4; i++ {
+		breakerCall(t, b, false)
+	}
+	// not enough requests
+	assert.Equal(t, StateClosed, b.State())
+	breakerCall(t, b, false)
+	assert.Equal(t, StateOpen, b.State())
+	_, err := b.Allow()
+	assert.Equal(t, ErrBreakerOpen, err)
+
+	clock.Advance(100 * time.Millisecond)
+	assert.Equal(t, StateHalfOpen, b.State())
+	done1, err := b.Allow()
+	assert.Nil(t, err)
+	done2, err := b.Allow()
+	assert.Nil(t, err)
+	// probes are exhausted
+	_, err = b.Allow()
+	assert.Equal(t, ErrBreakerOpen, err)
+	done1(true)
+	done2(true)
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, []BreakerState{StateOpen, StateHalfOpen, StateClosed}, changes)
+}
+
+func TestBreakerHalfOpenFailure(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	b := NewBreaker(append(optsForTest, WithClock(clock), WithMinRequests(1), WithOpenDuration(10*time.Millisecond))...)
+	breakerCall(t, b, false)
+	assert.Equal(t, StateOpen, b.State())
+	clock.Advance(10 * time.Millisecond)
+	breakerCall(t, b, false)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBreakerSlowCallRate(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	b := NewBreaker(append(optsForTest,
+		WithClock(clock),
+		WithMinRequests(2),
+		WithSlowCallDuration(10*time.Millisecond),
+		WithSlowCallRateThreshold(0.5))...)
+	breakerCall(t, b, true)
+	done, err := b.Allow()
+	assert.Nil(t, err)
+	clock.Advance(10 * time.Millisecond)
+	done(true)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestClientBreaker(t *testing.T) {
+	b := NewBreaker(append(optsForTest, WithMinRequests(2))...)
+	endpoint := breakerMiddleware(b)(func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+		resp.SetStatusCode(consts.StatusBadGateway)
+		return nil
+	})
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, endpoint(context.Background(), &protocol.Request{}, &protocol.Response{}))
+	}
+	assert.Equal(t, ErrBreakerOpen, endpoint(context.Background(), &protocol.Request{}, &protocol.Response{}))
+}
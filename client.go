@@ -0,0 +1,71 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/cloudwego/hertz/pkg/protocol"
+)
+
+// ClientLimit is a client middleware limiting outbound requests per destination host,
+// newLimiter is called once for each host, e.g.
+//
+//	limiter.ClientLimit(func() limiter.Limiter { return limiter.NewConcurrencyLimiter(limiter.NewVegas()) })
+//
+// Rejected requests fail fast with the error returned by the limiter, errors.Is(err, ErrLimitExceeded) holds.
+// Hosts are kept in a KeyStore bounded by WithMaxKeys and WithKeyTTL.
+// Throttler and Breaker don't implement Limiter, see ClientThrottlePerHost and ClientBreakerPerHost.
+func ClientLimit(newLimiter func() Limiter, opts ...Option) client.Middleware {
+	store := NewKeyStore(newLimiter, opts...)
+	store.Start()
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			done, err := store.Get(string(req.Host())).Allow()
+			if err != nil {
+				return err
+			}
+			defer done()
+			return next(ctx, req, resp)
+		}
+	}
+}
+
+// ClientThrottlePerHost is ClientThrottle with a Throttler for each destination host,
+// hosts are kept in a KeyStore bounded by WithMaxKeys and WithKeyTTL.
+func ClientThrottlePerHost(opts ...Option) client.Middleware {
+	store := newKeyStore(func() interface{} { return NewThrottler(opts...) }, opts...)
+	store.Start()
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			return roundTripThrottler(store.load(string(req.Host())).(*Throttler), next, ctx, req, resp)
+		}
+	}
+}
+
+// ClientBreakerPerHost is ClientBreaker with a Breaker for each destination host,
+// so a broken host doesn't stop the calls to others. Hosts are kept in a KeyStore bounded by WithMaxKeys and WithKeyTTL.
+func ClientBreakerPerHost(opts ...Option) client.Middleware {
+	store := newKeyStore(func() interface{} { return NewBreaker(opts...) }, opts...)
+	store.Start()
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			return roundTripBreaker(store.load(string(req.Host())).(*Breaker), next, ctx, req, resp)
+		}
+	}
+}
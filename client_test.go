@@ -0,0 +1,99 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientLimit(t *testing.T) {
+	var created int
+	mw := ClientLimit(func() Limiter {
+		created++
+		return NewConcurrencyLimiter(NewAIMD(0), WithInitialLimit(1))
+	})
+	newRequest := func(host string) *protocol.Request {
+		req := &protocol.Request{}
+		req.SetRequestURI("http://" + host + "/ping")
+		return req
+	}
+
+	var inner error
+	var endpoint func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error
+	endpoint = mw(func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+		if string(req.Host()) == "a.example.com" {
+			// the limit of a.example.com is exhausted while other hosts are not affected
+			inner = endpoint(ctx, newRequest("a.example.com"), resp)
+			return endpoint(ctx, newRequest("b.example.com"), resp)
+		}
+		return nil
+	})
+	assert.Nil(t, endpoint(context.Background(), newRequest("a.example.com"), &protocol.Response{}))
+	assert.Equal(t, ErrLimitExceeded, inner)
+	assert.Equal(t, 2, created)
+
+	// the limit is released after the request is done
+	assert.Nil(t, endpoint(context.Background(), newRequest("b.example.com"), &protocol.Response{}))
+}
+
+// hostEndpoint fails the requests to a.example.com by 502
+func hostEndpoint(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+	if string(req.Host()) == "a.example.com" {
+		resp.SetStatusCode(consts.StatusBadGateway)
+	} else {
+		resp.SetStatusCode(consts.StatusOK)
+	}
+	return nil
+}
+
+func hostRequest(host string) *protocol.Request {
+	req := &protocol.Request{}
+	req.SetRequestURI("http://" + host + "/ping")
+	return req
+}
+
+func TestClientBreakerPerHost(t *testing.T) {
+	endpoint := ClientBreakerPerHost(append(optsForTest, WithMinRequests(2))...)(hostEndpoint)
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, endpoint(context.Background(), hostRequest("a.example.com"), &protocol.Response{}))
+	}
+	err := endpoint(context.Background(), hostRequest("a.example.com"), &protocol.Response{})
+	assert.Equal(t, ErrBreakerOpen, err)
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+	// other hosts are not affected
+	assert.Nil(t, endpoint(context.Background(), hostRequest("b.example.com"), &protocol.Response{}))
+}
+
+func TestClientThrottlePerHost(t *testing.T) {
+	endpoint := ClientThrottlePerHost(optsForTest...)(hostEndpoint)
+	var rejected int
+	for i := 0; i < 100; i++ {
+		if err := endpoint(context.Background(), hostRequest("a.example.com"), &protocol.Response{}); err != nil {
+			assert.True(t, errors.Is(err, ErrLimitExceeded))
+			rejected++
+		}
+	}
+	assert.Greater(t, rejected, 0)
+	// a fresh throttler never rejects
+	assert.Nil(t, endpoint(context.Background(), hostRequest("b.example.com"), &protocol.Response{}))
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+// WindowSummary is the summary of BBR windows published by an instance,
+// MinRT is a duration, so instances may record RT in different RTUnit.
+type WindowSummary struct {
+	Instance string        `json:"instance"`
+	MaxPass  int64         `json:"max_pass"`
+	MinRT    time.Duration `json:"min_rt"`
+	Time     time.Time     `json:"time"`
+}
+
+// ClusterStore shares the window summaries across instances
+type ClusterStore interface {
+	// Publish stores the summary of an instance, overwriting the previous one
+	Publish(ctx context.Context, summary WindowSummary) error
+	// Summaries returns the latest summary of every instance
+	Summaries(ctx context.Context) ([]WindowSummary, error)
+}
+
+// clusterEstimate is the average capacity of fresh instances
+type clusterEstimate struct {
+	maxPass float64
+	minRT   float64 // in nanosecond
+}
+
+// clusterProc publishes the local summary and refreshes the cluster estimate every interval,
+// each round is bounded by the interval so that a hanging store doesn't stall the next ones.
+func (l *BBR) clusterProc() {
+	interval := l.config().opts.ClusterInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			l.syncCluster(ctx)
+			cancel()
+		}
+	}
+}
+
+// syncCluster publishes the local summary and refreshes the cluster estimate,
+// the estimate is cleared if the summaries can't be fetched or are all stale.
+func (l *BBR) syncCluster(ctx context.Context) {
+	opt := l.config().opts
+	summary := WindowSummary{
+		Instance: opt.ClusterInstance,
+		MaxPass:  l.maxPass(),
+		MinRT:    time.Duration(l.minRT()) * opt.RTUnit,
+		Time:     l.clock.Now(),
+	}
+	if err := opt.ClusterStore.Publish(ctx, summary); err != nil {
+		hlog.CtxWarnf(ctx, "HERTZ: Limiter publish window summary failed, error=%s", err.Error())
+	}
+	summaries, err := opt.ClusterStore.Summaries(ctx)
+	if err != nil {
+		hlog.CtxWarnf(ctx, "HERTZ: Limiter fetch window summaries failed, error=%s", err.Error())
+	}
+	l.cluster.Store(estimateCluster(summaries, summary.Time.Add(-3*opt.ClusterInterval)))
+}
+
+// estimateCluster averages summaries published after deadline
+func estimateCluster(summaries []WindowSummary, deadline time.Time) *clusterEstimate {
+	var c clusterEstimate
+	var n float64
+	for _, s := range summaries {
+		if s.Time.Before(deadline) {
+			continue
+		}
+		c.maxPass += float64(s.MaxPass)
+		c.minRT += float64(s.MinRT)
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	c.maxPass /= n
+	c.minRT /= n
+	return &c
+}
+
+// MemoryClusterStore is the in-process ClusterStore, useful for tests and instances in one process
+type MemoryClusterStore struct {
+	lock      sync.RWMutex
+	summaries map[string]WindowSummary
+}
+
+// NewMemoryClusterStore returns an empty MemoryClusterStore
+func NewMemoryClusterStore() *MemoryClusterStore {
+	return &MemoryClusterStore{summaries: make(map[string]WindowSummary)}
+}
+
+// Publish implements ClusterStore
+func (s *MemoryClusterStore) Publish(ctx context.Context, summary WindowSummary) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.summaries[summary.Instance] = summary
+	return nil
+}
+
+// Summaries implements ClusterStore
+func (s *MemoryClusterStore) Summaries(ctx context.Context) ([]WindowSummary, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	summaries := make([]WindowSummary, 0, len(s.summaries))
+	for _, summary := range s.summaries {
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
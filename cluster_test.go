@@ -0,0 +1,103 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hertz-contrib/limiter/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBBRCluster(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	store := NewMemoryClusterStore()
+	opts := append(optsForTest, WithClock(clock), WithClusterInterval(time.Hour), WithClusterWeight(0.5))
+	busy := NewLimiter(append(opts, WithCluster(store, "busy"))...)
+	idle := NewLimiter(append(opts, WithCluster(store, "idle"))...)
+	defer busy.Close()
+	defer idle.Close()
+
+	busy.passStat.Add(200)
+	busy.rtStat.Add(10)
+	idle.passStat.Add(20)
+	idle.rtStat.Add(10)
+	clock.Advance(bucketDuration)
+	// 20 * 10 * 10 / 1000
+	assert.Equal(t, int64(2), idle.maxInFlight())
+
+	busy.syncCluster(context.Background())
+	idle.syncCluster(context.Background())
+	// cluster max pass is 110, blended max pass is 65
+	assert.Equal(t, int64(7), idle.maxInFlight())
+
+	// the estimate is cleared once the cluster is unreachable
+	idle.conf.Store(newBBRConfig(NewOption(append(opts, WithCluster(brokenClusterStore{}, "idle"))...)))
+	idle.syncCluster(context.Background())
+	assert.Equal(t, int64(2), idle.maxInFlight())
+}
+
+func TestBBRClusterRTUnit(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	store := NewMemoryClusterStore()
+	opts := append(optsForTest, WithClock(clock), WithClusterInterval(time.Hour), WithClusterWeight(0.5))
+	milli := NewLimiter(append(opts, WithCluster(store, "milli"))...)
+	micro := NewLimiter(append(opts, WithCluster(store, "micro"), WithRTUnit(time.Microsecond))...)
+	defer milli.Close()
+	defer micro.Close()
+
+	// both take 10ms
+	milli.passStat.Add(20)
+	milli.rtStat.Add(10)
+	micro.passStat.Add(20)
+	micro.rtStat.Add(10000)
+	clock.Advance(windowSizeTest / time.Duration(bucketNumTest))
+	milli.syncCluster(context.Background())
+	micro.syncCluster(context.Background())
+	summaries, err := store.Summaries(context.Background())
+	assert.Nil(t, err)
+	for _, s := range summaries {
+		assert.Equal(t, 10*time.Millisecond, s.MinRT)
+	}
+	assert.Equal(t, int64(2), milli.maxInFlight())
+	assert.Equal(t, int64(2), micro.maxInFlight())
+}
+
+type brokenClusterStore struct{}
+
+func (brokenClusterStore) Publish(ctx context.Context, summary WindowSummary) error {
+	return errors.New("unreachable")
+}
+
+func (brokenClusterStore) Summaries(ctx context.Context) ([]WindowSummary, error) {
+	return nil, errors.New("unreachable")
+}
+
+func TestEstimateCluster(t *testing.T) {
+	now := time.Now()
+	assert.Nil(t, estimateCluster(nil, now))
+	c := estimateCluster([]WindowSummary{
+		{Instance: "a", MaxPass: 100, MinRT: 10 * time.Millisecond, Time: now},
+		{Instance: "b", MaxPass: 300, MinRT: 30 * time.Millisecond, Time: now},
+		{Instance: "stale", MaxPass: 1000, MinRT: time.Second, Time: now.Add(-time.Hour)},
+	}, now.Add(-time.Minute))
+	assert.Equal(t, &clusterEstimate{maxPass: 200, minRT: float64(20 * time.Millisecond)}, c)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
+
+// LimitAlgorithm adjusts a concurrency limit from the observed RTT.
+type LimitAlgorithm interface {
+	// Update returns the new limit based on current limit, the latest RTT sample,
+	// the inflight count when the sampled request started and whether any request was dropped since last update.
+	Update(limit int64, rtt time.Duration, inFlight int64, dropped bool) int64
+}
+
+// ConcurrencyLimiter limits the number of concurrent requests,
+// the limit is adjusted every bucket duration by a LimitAlgorithm.
+// It doesn't depend on CPU load, so it also protects I/O bound services.
+type ConcurrencyLimiter struct {
+	algo           LimitAlgorithm
+	rtStat         *utils.RollingWindow // time consume
+	limit          int64                // current concurrency limit
+	inFlight       int64                // Number of requests being processed
+	dropped        int32                // whether requests were dropped since last update
+	lastUpdate     int64                // unix nano of last limit update
+	bucketDuration time.Duration
+	mu             sync.Mutex // guards algo
+
+	opts options
+}
+
+// NewConcurrencyLimiter returns a ConcurrencyLimiter driven by algo
+func NewConcurrencyLimiter(algo LimitAlgorithm, opts ...Option) *ConcurrencyLimiter {
+	opt := NewOption(opts...)
+	bucketDuration := opt.Window / time.Duration(opt.Bucket)
+	return &ConcurrencyLimiter{
+		algo:           algo,
+		rtStat:         utils.NewRollingWindow(opt.Bucket, bucketDuration, utils.IgnoreCurrentBucket(), utils.WithClock(opt.Clock)),
+		limit:          clampLimit(opt.InitialLimit, opt),
+		lastUpdate:     opt.Clock.Now().UnixNano(),
+		bucketDuration: bucketDuration,
+		opts:           opt,
+	}
+}
+
+// Limit returns the current concurrency limit
+func (l *ConcurrencyLimiter) Limit() int64 {
+	return atomic.LoadInt64(&l.limit)
+}
+
+// Allow rejects the request when inflight requests reach the limit, record the interface time consumption
+func (l *ConcurrencyLimiter) Allow() (func(), error) {
+	inFlight := atomic.AddInt64(&l.inFlight, 1)
+	if inFlight > atomic.LoadInt64(&l.limit) {
+		atomic.AddInt64(&l.inFlight, -1)
+		atomic.StoreInt32(&l.dropped, 1)
+		return nil, ErrLimitExceeded
+	}
+	start := l.opts.Clock.Now()
+	return func() {
+		l.rtStat.Add(float64(l.opts.Clock.Now().Sub(start)))
+		atomic.AddInt64(&l.inFlight, -1)
+		l.tryUpdate(inFlight)
+	}, nil
+}
+
+// tryUpdate feeds the latest RTT sample to the algorithm at most once per bucket
+func (l *ConcurrencyLimiter) tryUpdate(inFlight int64) {
+	now := l.opts.Clock.Now().UnixNano()
+	last := atomic.LoadInt64(&l.lastUpdate)
+	if time.Duration(now-last) < l.bucketDuration || !atomic.CompareAndSwapInt64(&l.lastUpdate, last, now) {
+		return
+	}
+	rtt := l.sampleRT()
+	if rtt <= 0 {
+		return
+	}
+	dropped := atomic.SwapInt32(&l.dropped, 0) == 1
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit := l.algo.Update(atomic.LoadInt64(&l.limit), rtt, inFlight, dropped)
+	atomic.StoreInt64(&l.limit, clampLimit(limit, l.opts))
+}
+
+// sampleRT returns the average response time of the latest non-empty bucket
+func (l *ConcurrencyLimiter) sampleRT() time.Duration {
+	var rtt float64
+	l.rtStat.Reduce(func(b *utils.Bucket) {
+		if b.Count > 0 {
+			rtt = b.Sum / float64(b.Count)
+		}
+	})
+	return time.Duration(rtt)
+}
+
+func clampLimit(limit int64, opts options) int64 {
+	if limit < opts.MinLimit {
+		return opts.MinLimit
+	}
+	if limit > opts.MaxLimit {
+		return opts.MaxLimit
+	}
+	return limit
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hertz-contrib/limiter/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyLimiterAllow(t *testing.T) {
+	limiter := NewConcurrencyLimiter(NewAIMD(time.Second), WithInitialLimit(2))
+	done1, err := limiter.Allow()
+	assert.Nil(t, err)
+	done2, err := limiter.Allow()
+	assert.Nil(t, err)
+	_, err = limiter.Allow()
+	assert.Equal(t, ErrLimitExceeded, err)
+
+	done1()
+	done3, err := limiter.Allow()
+	assert.Nil(t, err)
+	done2()
+	done3()
+}
+
+func TestConcurrencyLimiterUpdate(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	limiter := NewConcurrencyLimiter(NewAIMD(time.Second), append(optsForTest, WithClock(clock), WithInitialLimit(2))...)
+	limiter.rtStat.Add(float64(10 * time.Millisecond))
+	clock.Advance(bucketDuration)
+	limiter.tryUpdate(2)
+	assert.Equal(t, int64(3), limiter.Limit())
+
+	// dropped requests back off the limit
+	limiter = NewConcurrencyLimiter(NewAIMD(time.Second), append(optsForTest, WithClock(clock), WithInitialLimit(20), WithMinLimit(19))...)
+	limiter.rtStat.Add(float64(10 * time.Millisecond))
+	limiter.dropped = 1
+	clock.Advance(bucketDuration)
+	limiter.tryUpdate(20)
+	assert.Equal(t, int64(19), limiter.Limit())
+}
+
+func TestVegas(t *testing.T) {
+	vegas := NewVegas()
+	// the first sample defines the no load rtt
+	assert.Equal(t, int64(100), vegas.Update(100, 10*time.Millisecond, 100, false))
+	// no queue, increase by beta
+	assert.Equal(t, int64(112), vegas.Update(100, 10*time.Millisecond, 100, false))
+	// queue size 50 > beta, decrease
+	assert.Equal(t, int64(98), vegas.Update(100, 20*time.Millisecond, 100, false))
+	// app limited
+	assert.Equal(t, int64(100), vegas.Update(100, 20*time.Millisecond, 10, false))
+	assert.Equal(t, int64(98), vegas.Update(100, 10*time.Millisecond, 100, true))
+}
+
+func TestGradient2(t *testing.T) {
+	gradient := NewGradient2(1.5)
+	limit := int64(20)
+	for i := 0; i < 20; i++ {
+		limit = gradient.Update(limit, 10*time.Millisecond, limit, false)
+	}
+	assert.Greater(t, limit, int64(20))
+
+	// rtt grows far beyond the long term rtt
+	prev := limit
+	for i := 0; i < 5; i++ {
+		limit = gradient.Update(limit, 100*time.Millisecond, limit, false)
+	}
+	assert.Less(t, limit, prev)
+}
+
+func TestAIMD(t *testing.T) {
+	aimd := NewAIMD(100 * time.Millisecond)
+	assert.Equal(t, int64(11), aimd.Update(10, 10*time.Millisecond, 5, false))
+	assert.Equal(t, int64(10), aimd.Update(10, 10*time.Millisecond, 4, false))
+	assert.Equal(t, int64(9), aimd.Update(10, 10*time.Millisecond, 5, true))
+	assert.Equal(t, int64(9), aimd.Update(10, time.Second, 5, false))
+}
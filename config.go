@@ -0,0 +1,412 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"gopkg.in/yaml.v3"
+)
+
+// RouteConfig configures the limiter of routes, the options are inlined, e.g.
+//
+//	path: /api/*
+//	algorithm: bbr
+//	cpu_threshold: 700
+//	window: 5s
+type RouteConfig struct {
+	// Path matches the request path exactly, or by prefix if it ends with "*"
+	Path string `yaml:"path" json:"path"`
+	// Algorithm is one of bbr, vegas, gradient2 and aimd, default is bbr
+	Algorithm string `yaml:"algorithm" json:"algorithm"`
+	// AIMDTimeout is the timeout of aimd, slower requests are treated as drops, default is 1s
+	AIMDTimeout time.Duration `yaml:"aimd_timeout" json:"aimd_timeout"`
+	// Key is one of ip, header:<name>, param:<name> and query:<name>, empty means all requests share one limiter
+	Key string `yaml:"key" json:"key"`
+	// TrustedProxies are the proxies whose X-Forwarded-For is honored when Key is ip
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
+
+	options `yaml:",inline"`
+}
+
+// Config describes the limiters declaratively in YAML or JSON. Routes inherit the options of Default,
+// requests matching Skip are not limited, and ${ENV} in the file is expanded.
+type Config struct {
+	Default RouteConfig   `yaml:"default" json:"default"`
+	Routes  []RouteConfig `yaml:"routes" json:"routes"`
+	Skip    []string      `yaml:"skip" json:"skip"`
+}
+
+type rawConfig struct {
+	Default yaml.Node   `yaml:"default"`
+	Routes  []yaml.Node `yaml:"routes"`
+	Skip    []string    `yaml:"skip"`
+}
+
+// ParseConfig parses the config in YAML or JSON
+func ParseConfig(data []byte) (*Config, error) {
+	return parseConfig([]byte(os.ExpandEnv(string(data))))
+}
+
+// parseConfig parses the config whose environment variables have been expanded
+func parseConfig(data []byte) (*Config, error) {
+	var raw rawConfig
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		Default: RouteConfig{AIMDTimeout: time.Second, options: NewOption()},
+		Skip:    raw.Skip,
+	}
+	if !raw.Default.IsZero() {
+		if err := raw.Default.Decode(&cfg.Default); err != nil {
+			return nil, err
+		}
+		markSampling(&raw.Default, &cfg.Default.options)
+	}
+	if err := cfg.Default.validate(); err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+	for i := range raw.Routes {
+		route := cfg.Default
+		route.Path = ""
+		if err := raw.Routes[i].Decode(&route); err != nil {
+			return nil, err
+		}
+		markSampling(&raw.Routes[i], &route.options)
+		if route.Path == "" {
+			return nil, fmt.Errorf("routes[%d]: path is required", i)
+		}
+		if err := route.validate(); err != nil {
+			return nil, fmt.Errorf("routes[%d]: %w", i, err)
+		}
+		cfg.Routes = append(cfg.Routes, route)
+	}
+	return cfg, nil
+}
+
+// markSampling marks SamplingTime and Decay of o as set if node has them, like WithSamplingTime and WithDecay
+func markSampling(node *yaml.Node, o *options) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		switch node.Content[i].Value {
+		case "sampling_time":
+			o.samplingTimeSet = true
+		case "decay":
+			o.decaySet = true
+		}
+	}
+}
+
+// LoadConfig reads the config file in YAML or JSON
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+func (r *RouteConfig) validate() error {
+	if err := r.options.validate(); err != nil {
+		return err
+	}
+	if r.Algorithm == "aimd" && r.AIMDTimeout <= 0 {
+		return fmt.Errorf("invalid aimd timeout %s", r.AIMDTimeout)
+	}
+	switch r.Algorithm {
+	case "", "bbr", "vegas", "gradient2", "aimd":
+	default:
+		return fmt.Errorf("unknown algorithm %q", r.Algorithm)
+	}
+	_, err := r.keyFunc()
+	return err
+}
+
+func (r *RouteConfig) keyFunc() (func(c context.Context, ctx *app.RequestContext) string, error) {
+	kind, name, _ := cut(r.Key, ":")
+	switch {
+	case r.Key == "":
+		return nil, nil
+	case kind == "ip":
+		return KeyByClientIP(r.TrustedProxies...), nil
+	case kind == "header" && name != "":
+		return KeyByHeader(name), nil
+	case kind == "param" && name != "":
+		return KeyByParam(name), nil
+	case kind == "query" && name != "":
+		return KeyByQuery(name), nil
+	}
+	return nil, fmt.Errorf("invalid key %q", r.Key)
+}
+
+func (r *RouteConfig) newLimiter() Limiter {
+	switch r.Algorithm {
+	case "vegas":
+		return NewConcurrencyLimiter(NewVegas(), withOptions(r.options))
+	case "gradient2":
+		return NewConcurrencyLimiter(NewGradient2(1.5), withOptions(r.options))
+	case "aimd":
+		return NewConcurrencyLimiter(NewAIMD(r.AIMDTimeout), withOptions(r.options))
+	}
+	return NewLimiter(withOptions(r.options))
+}
+
+// withOptions replaces all options by o
+func withOptions(o options) Option {
+	return func(dst *options) {
+		*dst = o
+	}
+}
+
+// cut is strings.Cut, which requires go 1.18
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// ConfigLimiter limits requests by a Config, which can be reloaded from its file
+// and applied atomically while serving.
+type ConfigLimiter struct {
+	path      string
+	lock      sync.Mutex // serializes Apply
+	routes    atomic.Value
+	content   []byte
+	stop      chan struct{}
+	watching  sync.WaitGroup
+	closeOnce sync.Once
+}
+
+type configRoutes struct {
+	exact    map[string]*configRoute
+	prefixes []*configRoute // longest prefix first
+	def      *configRoute
+	skip     []string
+}
+
+type configRoute struct {
+	conf    RouteConfig
+	handler app.HandlerFunc
+	bbr     *BBR      // kept to be updated in place
+	store   *KeyStore // closed when replaced
+}
+
+// NewConfigLimiter loads the config file at path, call Watch to reload it on changes
+func NewConfigLimiter(path string) (*ConfigLimiter, error) {
+	l := &ConfigLimiter{
+		path: path,
+		stop: make(chan struct{}),
+	}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Handler returns the middleware limiting requests by the current config
+func (l *ConfigLimiter) Handler() app.HandlerFunc {
+	return func(c context.Context, ctx *app.RequestContext) {
+		routes := l.routes.Load().(*configRoutes)
+		path := string(ctx.Path())
+		for _, pattern := range routes.skip {
+			if matchPath(pattern, path) {
+				ctx.Next(c)
+				return
+			}
+		}
+		routes.match(path).handler(c, ctx)
+	}
+}
+
+func matchPath(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(path, pattern[:len(pattern)-1])
+	}
+	return pattern == path
+}
+
+func (r *configRoutes) match(path string) *configRoute {
+	if route, ok := r.exact[path]; ok {
+		return route
+	}
+	for _, route := range r.prefixes {
+		if matchPath(route.conf.Path, path) {
+			return route
+		}
+	}
+	return r.def
+}
+
+// Reload reads the config file and applies it if the content or the environment variables changed
+func (l *ConfigLimiter) Reload() error {
+	raw, err := os.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+	data := []byte(os.ExpandEnv(string(raw)))
+	l.lock.Lock()
+	unchanged := l.content != nil && bytes.Equal(data, l.content)
+	l.lock.Unlock()
+	if unchanged {
+		return nil
+	}
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return err
+	}
+	l.Apply(cfg)
+	l.lock.Lock()
+	l.content = data
+	l.lock.Unlock()
+	return nil
+}
+
+// Apply swaps the config atomically. BBR limiters without key are updated in place to keep their history,
+// other limiters are recreated.
+func (l *ConfigLimiter) Apply(cfg *Config) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	prev := make(map[string]*configRoute)
+	if old, ok := l.routes.Load().(*configRoutes); ok {
+		prev[""] = old.def
+		for _, route := range old.exact {
+			prev[route.conf.Path] = route
+		}
+		for _, route := range old.prefixes {
+			prev[route.conf.Path] = route
+		}
+	}
+
+	routes := &configRoutes{
+		exact: make(map[string]*configRoute),
+		skip:  cfg.Skip,
+	}
+	reused := make(map[*configRoute]bool)
+	build := func(conf RouteConfig) *configRoute {
+		// routes are validated by ParseConfig, so Update only fails for a hand-built Config
+		if old, ok := prev[conf.Path]; ok && old.bbr != nil && conf.Key == "" &&
+			(conf.Algorithm == "" || conf.Algorithm == "bbr") && old.bbr.Update(withOptions(conf.options)) == nil {
+			reused[old] = true
+			return &configRoute{conf: conf, handler: old.handler, bbr: old.bbr}
+		}
+		return newConfigRoute(conf)
+	}
+	def := cfg.Default
+	def.Path = ""
+	routes.def = build(def)
+	for _, conf := range cfg.Routes {
+		route := build(conf)
+		if strings.HasSuffix(conf.Path, "*") {
+			routes.prefixes = append(routes.prefixes, route)
+		} else {
+			routes.exact[conf.Path] = route
+		}
+	}
+	sort.SliceStable(routes.prefixes, func(i, j int) bool {
+		return len(routes.prefixes[i].conf.Path) > len(routes.prefixes[j].conf.Path)
+	})
+	l.routes.Store(routes)
+
+	for _, old := range prev {
+		if !reused[old] {
+			old.close()
+		}
+	}
+}
+
+// all returns the routes including the default one
+func (r *configRoutes) all() []*configRoute {
+	routes := append([]*configRoute{r.def}, r.prefixes...)
+	for _, route := range r.exact {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// close closes the limiters of the route
+func (r *configRoute) close() {
+	if r.store != nil {
+		r.store.Close()
+	}
+	if r.bbr != nil {
+		r.bbr.Close()
+	}
+}
+
+func newConfigRoute(conf RouteConfig) *configRoute {
+	route := &configRoute{conf: conf}
+	keyFunc, _ := conf.keyFunc()
+	if keyFunc != nil {
+		route.store = NewKeyStore(conf.newLimiter, withOptions(conf.options))
+		route.store.Start()
+		route.handler = KeyedLimitHandler(route.store, keyFunc)
+		return route
+	}
+	limiter := conf.newLimiter()
+	route.bbr, _ = limiter.(*BBR)
+	route.handler = LimitHandler(limiter)
+	return route
+}
+
+// Watch reloads the config file every interval until Close, errors are logged and the current config is kept
+func (l *ConfigLimiter) Watch(interval time.Duration) {
+	l.watching.Add(1)
+	go func() {
+		defer l.watching.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				select {
+				case <-l.stop:
+					return
+				default:
+				}
+				if err := l.Reload(); err != nil {
+					hlog.Warnf("HERTZ: Limiter reload config %s failed, error=%s", l.path, err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// Close stops watching the config file and closes the limiters of all routes
+func (l *ConfigLimiter) Close() {
+	l.closeOnce.Do(func() {
+		close(l.stop)
+		l.watching.Wait()
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		for _, route := range l.routes.Load().(*configRoutes).all() {
+			route.close()
+		}
+	})
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `
+default:
+  window: 5s
+  bucket: 50
+  cpu_threshold: ${TEST_CPU_THRESHOLD}
+skip:
+  - /health
+  - /static/*
+routes:
+  - path: /api/*
+    algorithm: aimd
+    aimd_timeout: 200ms
+    initial_limit: 1
+    key: header:X-Tenant
+  - path: /api/upload
+    cpu_threshold: 900
+`
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("TEST_CPU_THRESHOLD", "700")
+	cfg, err := ParseConfig([]byte(testConfig))
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Second, cfg.Default.Window)
+	assert.Equal(t, int64(700), cfg.Default.CPUThreshold)
+	// unset options keep the defaults
+	assert.Equal(t, 0.95, cfg.Default.Decay)
+	assert.False(t, cfg.Default.decaySet)
+	assert.Equal(t, []string{"/health", "/static/*"}, cfg.Skip)
+
+	// routes inherit the default options
+	assert.Equal(t, "aimd", cfg.Routes[0].Algorithm)
+	assert.Equal(t, 200*time.Millisecond, cfg.Routes[0].AIMDTimeout)
+	assert.Equal(t, time.Second, cfg.Routes[1].AIMDTimeout)
+	assert.Equal(t, NewAIMD(200*time.Millisecond), cfg.Routes[0].newLimiter().(*ConcurrencyLimiter).algo)
+	assert.Equal(t, 50, cfg.Routes[0].Bucket)
+	assert.Equal(t, int64(1), cfg.Routes[0].InitialLimit)
+	assert.Equal(t, int64(900), cfg.Routes[1].CPUThreshold)
+	assert.Equal(t, "", cfg.Routes[1].Key)
+
+	cfg, err = ParseConfig([]byte(`{"default": {"window": "2s", "bucket": 20}, "routes": [{"path": "/a", "key": "ip"}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Second, cfg.Routes[0].Window)
+
+	// sampling set in the file is applied to the shared sampler
+	cfg, err = ParseConfig([]byte(`{"default": {"decay": 0.95}, "routes": [{"path": "/a", "sampling_time": "1s"}]}`))
+	assert.Nil(t, err)
+	assert.True(t, cfg.Default.decaySet)
+	assert.False(t, cfg.Default.samplingTimeSet)
+	assert.True(t, cfg.Routes[0].decaySet)
+	assert.True(t, cfg.Routes[0].samplingTimeSet)
+
+	for _, invalid := range []string{
+		`default: {algorithm: unknown}`,
+		`default: {bucket: 0}`,
+		`default: {decay: 1}`,
+		`default: {algorithm: aimd, aimd_timeout: 0s}`,
+		`routes: [{key: ip}]`,
+		`routes: [{path: /a, key: "header:"}]`,
+		`default: [`,
+	} {
+		_, err = ParseConfig([]byte(invalid))
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestConfigLimiter(t *testing.T) {
+	t.Setenv("TEST_CPU_THRESHOLD", "700")
+	path := filepath.Join(t.TempDir(), "limiter.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfig), 0o644))
+	l, err := NewConfigLimiter(path)
+	assert.Nil(t, err)
+	defer l.Close()
+
+	routes := l.routes.Load().(*configRoutes)
+	assert.Equal(t, int64(900), routes.match("/api/upload").bbr.config().opts.CPUThreshold)
+	assert.NotNil(t, routes.match("/api/users").store)
+	def := routes.match("/other").bbr
+	assert.Equal(t, int64(700), def.config().opts.CPUThreshold)
+
+	// skip and keyed routes
+	var status []int
+	var handlers app.HandlersChain
+	serve := func(path string) int {
+		ctx := app.NewContext(0)
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.Set("X-Tenant", "a")
+		ctx.SetHandlers(handlers)
+		ctx.Next(context.Background())
+		return ctx.Response.StatusCode()
+	}
+	handlers = app.HandlersChain{l.Handler(), func(c context.Context, ctx *app.RequestContext) {
+		if string(ctx.Path()) == "/api/users" {
+			status = append(status, serve("/api/users"), serve("/health"))
+		}
+	}}
+	assert.Equal(t, consts.StatusOK, serve("/api/users"))
+	assert.Equal(t, []int{consts.StatusTooManyRequests, consts.StatusOK}, status)
+
+	// reload keeps the BBR limiter and its history
+	t.Setenv("TEST_CPU_THRESHOLD", "600")
+	assert.Nil(t, l.Reload())
+	routes = l.routes.Load().(*configRoutes)
+	assert.Same(t, def, routes.match("/other").bbr)
+	assert.Equal(t, int64(600), def.config().opts.CPUThreshold)
+
+	// invalid config is not applied
+	assert.Nil(t, os.WriteFile(path, []byte(`default: {bucket: 0}`), 0o644))
+	assert.NotNil(t, l.Reload())
+	assert.Same(t, routes, l.routes.Load().(*configRoutes))
+}
+
+func TestConfigLimiterWatch(t *testing.T) {
+	t.Setenv("TEST_CPU_THRESHOLD", "700")
+	path := filepath.Join(t.TempDir(), "limiter.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfig), 0o644))
+	l, err := NewConfigLimiter(path)
+	assert.Nil(t, err)
+	routes := l.routes.Load().(*configRoutes)
+
+	// unchanged content is not applied again
+	assert.Nil(t, l.Reload())
+	assert.Same(t, routes, l.routes.Load().(*configRoutes))
+
+	keyed := routes.match("/api/users")
+	ctx := app.NewContext(0)
+	ctx.Request.Header.Set("X-Tenant", "a")
+	keyed.handler(context.Background(), ctx)
+	assert.Equal(t, 1, keyed.store.Stats().Keys)
+
+	// changes are applied by Watch
+	l.Watch(10 * time.Millisecond)
+	assert.Nil(t, os.WriteFile(path, []byte(`default: {cpu_threshold: 500}`), 0o644))
+	assert.Eventually(t, func() bool {
+		return l.routes.Load().(*configRoutes).def.bbr.config().opts.CPUThreshold == 500
+	}, time.Second, 10*time.Millisecond)
+	def := l.routes.Load().(*configRoutes).def.bbr
+	assert.Same(t, routes.def.bbr, def)
+	// the replaced keyed route is closed after the new routes are stored
+	assert.Eventually(t, func() bool {
+		return keyed.store.Stats().Keys == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// Close stops watching and closes the limiters
+	l.Close()
+	l.Close()
+	_, ok := <-def.stop
+	assert.False(t, ok)
+	assert.Nil(t, os.WriteFile(path, []byte(`default: {cpu_threshold: 400}`), 0o644))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(500), def.config().opts.CPUThreshold)
+}
@@ -0,0 +1,277 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// OverloadDetector decides whether the system is overloaded, so BBR starts dropping
+// when the inflight count exceeds the estimated capacity.
+type OverloadDetector interface {
+	Overloaded() bool
+}
+
+// HysteresisDetector is an optional interface of OverloadDetector, once BBR is dropping it asks
+// StillOverloaded instead of Overloaded, which should use a lower threshold so the dropping doesn't flap.
+type HysteresisDetector interface {
+	OverloadDetector
+	StillOverloaded() bool
+}
+
+// PressureDetector is an optional interface of OverloadDetector, with WithDropSpan the pressure raises
+// the drop probability towards 1, from 0 at the threshold to 1 fully saturated.
+type PressureDetector interface {
+	OverloadDetector
+	Pressure() float64
+}
+
+// stillOverloaded asks d once dropping, see HysteresisDetector
+func stillOverloaded(d OverloadDetector) bool {
+	if h, ok := d.(HysteresisDetector); ok {
+		return h.StillOverloaded()
+	}
+	return d.Overloaded()
+}
+
+// pressure returns the pressure of d, 0 if it's not a PressureDetector
+func pressure(d OverloadDetector) float64 {
+	if p, ok := d.(PressureDetector); ok {
+		return math.Max(math.Min(p.Pressure(), 1), 0)
+	}
+	return 0
+}
+
+// DetectorFunc adapts a func to OverloadDetector, e.g. to report the saturation of a DB pool
+type DetectorFunc func() bool
+
+// Overloaded calls f
+func (f DetectorFunc) Overloaded() bool {
+	return f()
+}
+
+// CPUDetector is overloaded when the CPU load sampled from /proc/stat reaches threshold, e.g. 800 means 80%,
+// it's a PressureDetector by the CPU load over threshold.
+func CPUDetector(threshold int64) OverloadDetector {
+	return cpuDetector(threshold)
+}
+
+type cpuDetector int64
+
+func (d cpuDetector) Overloaded() bool {
+	return atomic.LoadInt64(&gCPU) >= int64(d)
+}
+
+func (d cpuDetector) Pressure() float64 {
+	return cpuPressure(atomic.LoadInt64(&gCPU), int64(d))
+}
+
+// cpuPressure is the CPU load over threshold scaled to [0, 1]
+func cpuPressure(cpu, threshold int64) float64 {
+	if threshold >= 1000 || cpu <= threshold {
+		return 0
+	}
+	return math.Min(float64(cpu-threshold)/float64(1000-threshold), 1)
+}
+
+// MemoryDetector is overloaded when the memory usage against GOMEMLIMIT or the cgroup limit reaches threshold
+func MemoryDetector(threshold int64) OverloadDetector {
+	return DetectorFunc(func() bool {
+		return atomic.LoadInt64(&gMem) >= threshold
+	})
+}
+
+// SchedLatencyDetector is overloaded when the p99 go scheduling latency reaches threshold
+func SchedLatencyDetector(threshold time.Duration) OverloadDetector {
+	return DetectorFunc(func() bool {
+		return atomic.LoadInt64(&gSchedLatency) >= int64(threshold)
+	})
+}
+
+// GoroutineDetector is overloaded when the goroutine count reaches threshold
+func GoroutineDetector(threshold int64) OverloadDetector {
+	return DetectorFunc(func() bool {
+		return atomic.LoadInt64(&gGoroutines) >= threshold
+	})
+}
+
+// AnyOf is overloaded when any of detectors is overloaded, the hysteresis and the pressure of detectors are kept
+func AnyOf(detectors ...OverloadDetector) OverloadDetector {
+	return anyOf(detectors)
+}
+
+type anyOf []OverloadDetector
+
+func (a anyOf) Overloaded() bool {
+	for _, d := range a {
+		if d.Overloaded() {
+			return true
+		}
+	}
+	return false
+}
+
+func (a anyOf) StillOverloaded() bool {
+	for _, d := range a {
+		if stillOverloaded(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pressure is the highest pressure of detectors
+func (a anyOf) Pressure() float64 {
+	var p float64
+	for _, d := range a {
+		p = math.Max(p, pressure(d))
+	}
+	return p
+}
+
+// systemDetector is the default OverloadDetector of BBR checking the CPU load, memory usage,
+// scheduling latency and goroutine count, the CPU load is compared with CPUExitThreshold once dropping.
+type systemDetector struct {
+	l *BBR
+}
+
+func (d systemDetector) Overloaded() bool {
+	return d.overloaded(d.l.config().opts.CPUThreshold)
+}
+
+func (d systemDetector) StillOverloaded() bool {
+	opts := d.l.config().opts
+	if opts.CPUExitThreshold > 0 {
+		return d.overloaded(opts.CPUExitThreshold)
+	}
+	return d.overloaded(opts.CPUThreshold)
+}
+
+func (d systemDetector) overloaded(cpuThreshold int64) bool {
+	opts, l := d.l.config().opts, d.l
+	if !opts.DisableCPU && l.cpu() >= cpuThreshold {
+		return true
+	}
+	if opts.MemoryThreshold > 0 && l.memory() >= opts.MemoryThreshold {
+		return true
+	}
+	if opts.SchedLatencyThreshold > 0 && l.schedLatency() >= int64(opts.SchedLatencyThreshold) {
+		return true
+	}
+	return opts.GoroutineThreshold > 0 && l.goroutines() >= opts.GoroutineThreshold
+}
+
+// Pressure is the CPU load over CPUThreshold
+func (d systemDetector) Pressure() float64 {
+	opts := d.l.config().opts
+	if opts.DisableCPU {
+		return 0
+	}
+	return cpuPressure(d.l.cpu(), opts.CPUThreshold)
+}
+
+// AllOf is overloaded when all of detectors are overloaded, it's never overloaded without detectors,
+// the hysteresis and the pressure of detectors are kept.
+func AllOf(detectors ...OverloadDetector) OverloadDetector {
+	return allOf(detectors)
+}
+
+type allOf []OverloadDetector
+
+func (a allOf) Overloaded() bool {
+	for _, d := range a {
+		if !d.Overloaded() {
+			return false
+		}
+	}
+	return len(a) > 0
+}
+
+func (a allOf) StillOverloaded() bool {
+	for _, d := range a {
+		if !stillOverloaded(d) {
+			return false
+		}
+	}
+	return len(a) > 0
+}
+
+// Pressure is the lowest pressure of detectors
+func (a allOf) Pressure() float64 {
+	if len(a) == 0 {
+		return 0
+	}
+	p := float64(1)
+	for _, d := range a {
+		p = math.Min(p, pressure(d))
+	}
+	return p
+}
+
+// WeightedDetector is a detector with its weight in Weighted
+type WeightedDetector struct {
+	Detector OverloadDetector
+	Weight   float64
+}
+
+// Weighted is overloaded when the weights of the overloaded detectors sum up to threshold,
+// e.g. Weighted(1, {cpu, 0.6}, {memory, 0.6}, {sched, 0.4}) needs two of them.
+// The hysteresis and the pressure of detectors are kept.
+func Weighted(threshold float64, detectors ...WeightedDetector) OverloadDetector {
+	return weighted{threshold: threshold, detectors: detectors}
+}
+
+type weighted struct {
+	threshold float64
+	detectors []WeightedDetector
+}
+
+func (w weighted) Overloaded() bool {
+	return w.reached(OverloadDetector.Overloaded)
+}
+
+func (w weighted) StillOverloaded() bool {
+	return w.reached(stillOverloaded)
+}
+
+// reached reports whether the weights of the detectors overloaded by f sum up to threshold
+func (w weighted) reached(f func(OverloadDetector) bool) bool {
+	var score float64
+	for _, d := range w.detectors {
+		if f(d.Detector) {
+			score += d.Weight
+		}
+	}
+	return score > 0 && score >= w.threshold
+}
+
+// Pressure is the mean pressure of detectors by their weights
+func (w weighted) Pressure() float64 {
+	var p, total float64
+	for _, d := range w.detectors {
+		if d.Weight > 0 {
+			p += d.Weight * pressure(d.Detector)
+			total += d.Weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return p / total
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hertz-contrib/limiter/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	overloaded = DetectorFunc(func() bool { return true })
+	idle       = DetectorFunc(func() bool { return false })
+)
+
+func TestAnyOf(t *testing.T) {
+	assert.Equal(t, false, AnyOf().Overloaded())
+	assert.Equal(t, false, AnyOf(idle, idle).Overloaded())
+	assert.Equal(t, true, AnyOf(idle, overloaded).Overloaded())
+}
+
+func TestAllOf(t *testing.T) {
+	assert.Equal(t, false, AllOf().Overloaded())
+	assert.Equal(t, false, AllOf(idle, overloaded).Overloaded())
+	assert.Equal(t, true, AllOf(overloaded, overloaded).Overloaded())
+	// nested
+	assert.Equal(t, true, AllOf(overloaded, AnyOf(idle, overloaded)).Overloaded())
+
+	// hysteresis and pressure
+	used, full := int64(95), int64(100)
+	d := AllOf(overloaded, poolDetector{&used})
+	assert.Equal(t, true, stillOverloaded(d))
+	assert.Equal(t, float64(0), pressure(d))
+	assert.Equal(t, 0.5, pressure(AllOf(poolDetector{&full}, poolDetector{&used})))
+	used = 80
+	assert.Equal(t, false, d.Overloaded())
+	assert.Equal(t, true, stillOverloaded(d))
+	used = 60
+	assert.Equal(t, false, stillOverloaded(d))
+	assert.Equal(t, false, stillOverloaded(AllOf()))
+	assert.Equal(t, float64(0), pressure(AllOf()))
+}
+
+func TestWeighted(t *testing.T) {
+	assert.Equal(t, false, Weighted(0).Overloaded())
+	assert.Equal(t, false, Weighted(1,
+		WeightedDetector{overloaded, 0.6},
+		WeightedDetector{idle, 0.6},
+		WeightedDetector{idle, 0.4}).Overloaded())
+	assert.Equal(t, true, Weighted(1,
+		WeightedDetector{overloaded, 0.6},
+		WeightedDetector{idle, 0.6},
+		WeightedDetector{overloaded, 0.4}).Overloaded())
+
+	// hysteresis and pressure
+	used := int64(95)
+	d := Weighted(1, WeightedDetector{overloaded, 0.5}, WeightedDetector{poolDetector{&used}, 0.5})
+	assert.Equal(t, true, d.Overloaded())
+	assert.Equal(t, 0.25, pressure(d))
+	used = 80
+	assert.Equal(t, false, d.Overloaded())
+	assert.Equal(t, true, stillOverloaded(d))
+	used = 60
+	assert.Equal(t, false, stillOverloaded(d))
+	assert.Equal(t, float64(0), pressure(Weighted(0)))
+}
+
+func TestBuiltinDetectors(t *testing.T) {
+	assert.Equal(t, true, CPUDetector(0).Overloaded())
+	assert.Equal(t, false, CPUDetector(1001).Overloaded())
+	assert.Equal(t, false, MemoryDetector(1<<62).Overloaded())
+	assert.Equal(t, false, SchedLatencyDetector(1<<62).Overloaded())
+	assert.Equal(t, false, GoroutineDetector(1<<62).Overloaded())
+}
+
+func TestBBRShouldDropByDetector(t *testing.T) {
+	var poolSaturated bool
+	// the built-in cpu threshold is replaced
+	cpu := int64(1000)
+	bbr := newDropTestLimiter(utils.NewFakeClock(time.Now()), &cpu, WithOverloadDetector(AnyOf(CPUDetector(1001), DetectorFunc(func() bool {
+		return poolSaturated
+	}))))
+	assert.Equal(t, false, bbr.shouldDrop())
+	poolSaturated = true
+	assert.Equal(t, true, bbr.shouldDrop())
+
+	// system rules still apply
+	poolSaturated = false
+	assert.Nil(t, bbr.Update(WithMaxConcurrency(50)))
+	assert.Equal(t, true, bbr.overloaded())
+}
+
+// poolDetector reports the saturation of a pool with hysteresis and pressure
+type poolDetector struct {
+	used *int64
+}
+
+func (d poolDetector) Overloaded() bool      { return *d.used >= 90 }
+func (d poolDetector) StillOverloaded() bool { return *d.used >= 70 }
+func (d poolDetector) Pressure() float64     { return float64(*d.used-90) / 10 }
+
+func TestBBRDetectorHysteresis(t *testing.T) {
+	used, cpu := int64(95), int64(0)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := newDropTestLimiter(clock, &cpu, WithOverloadDetector(AnyOf(idle, poolDetector{&used})), WithCoolDown(0))
+	assert.Equal(t, true, bbr.shouldDrop())
+	// below the threshold but not recovered
+	used = 80
+	clock.Advance(time.Second)
+	assert.Equal(t, true, bbr.overloaded())
+	assert.Equal(t, phaseDropping, bbr.phase())
+	used = 60
+	assert.Equal(t, false, bbr.overloaded())
+}
+
+func TestBBRDetectorPressure(t *testing.T) {
+	used, cpu := int64(95), int64(0)
+	bbr := newDropTestLimiter(utils.NewFakeClock(time.Now()), &cpu, WithOverloadDetector(poolDetector{&used}), WithDropSpan(1))
+	// half of the span exceeded, and half of the pool pressure
+	assert.Equal(t, 0.75, bbr.dropProbability(bbr.config().opts, 3, 2))
+	used = 100
+	assert.Equal(t, float64(1), bbr.dropProbability(bbr.config().opts, 3, 2))
+	// not a PressureDetector
+	assert.Nil(t, bbr.Update(WithOverloadDetector(overloaded)))
+	assert.Equal(t, 0.5, bbr.dropProbability(bbr.config().opts, 3, 2))
+}
+
+func TestCPUDetectorPressure(t *testing.T) {
+	assert.Equal(t, float64(0), pressure(CPUDetector(1000)))
+	assert.Equal(t, 0.5, cpuPressure(900, 800))
+	assert.Equal(t, float64(0), cpuPressure(700, 800))
+	assert.Equal(t, float64(1), cpuPressure(1200, 800))
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// KeyByClientIP uses the client IP as key. X-Forwarded-For is only honored when the
+// request comes from trustedProxies, which can be IPs or CIDRs, e.g. "10.0.0.0/8".
+// The rightmost untrusted address in X-Forwarded-For is the client IP.
+func KeyByClientIP(trustedProxies ...string) func(c context.Context, ctx *app.RequestContext) string {
+	trusted := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			panic("invalid trusted proxy: " + proxy)
+		}
+		trusted = append(trusted, ipNet)
+	}
+	return func(c context.Context, ctx *app.RequestContext) string {
+		return clientIP(ctx.RemoteAddr().String(), string(ctx.GetHeader("X-Forwarded-For")), trusted)
+	}
+}
+
+// clientIP walks the proxy chain from right to left until an untrusted address
+func clientIP(remoteAddr, forwardedFor string, trusted []*net.IPNet) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+	if forwardedFor == "" || !isTrusted(ip, trusted) {
+		return ip
+	}
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !isTrusted(ip, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+func isTrusted(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyByHeader uses the value of header as key, e.g. "X-Tenant-ID"
+func KeyByHeader(header string) func(c context.Context, ctx *app.RequestContext) string {
+	return func(c context.Context, ctx *app.RequestContext) string {
+		return string(ctx.GetHeader(header))
+	}
+}
+
+// KeyByParam uses the value of path parameter as key, e.g. "user" for "/users/:user"
+func KeyByParam(param string) func(c context.Context, ctx *app.RequestContext) string {
+	return func(c context.Context, ctx *app.RequestContext) string {
+		return ctx.Param(param)
+	}
+}
+
+// KeyByQuery uses the value of query arg as key
+func KeyByQuery(arg string) func(c context.Context, ctx *app.RequestContext) string {
+	return func(c context.Context, ctx *app.RequestContext) string {
+		return ctx.Query(arg)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := []*net.IPNet{proxies}
+
+	assert.Equal(t, "1.1.1.1", clientIP("1.1.1.1:80", "", trusted))
+	// untrusted remote can't spoof X-Forwarded-For
+	assert.Equal(t, "1.1.1.1", clientIP("1.1.1.1:80", "2.2.2.2", trusted))
+	assert.Equal(t, "3.3.3.3", clientIP("10.0.0.1:80", "2.2.2.2, 3.3.3.3, 10.0.0.2", trusted))
+	// all hops are trusted
+	assert.Equal(t, "10.0.0.3", clientIP("10.0.0.1:80", "10.0.0.3, 10.0.0.2", trusted))
+}
+
+func TestKeyByClientIP(t *testing.T) {
+	assert.Panics(t, func() {
+		KeyByClientIP("not an ip")
+	})
+	ctx := app.NewContext(0)
+	ctx.Request.Header.Set("X-Forwarded-For", "2.2.2.2")
+	// remote is 0.0.0.0 without connection
+	assert.Equal(t, "2.2.2.2", KeyByClientIP("0.0.0.0")(context.Background(), ctx))
+	assert.Equal(t, "0.0.0.0", KeyByClientIP()(context.Background(), ctx))
+}
+
+func TestKeyedLimitWithCluster(t *testing.T) {
+	assert.Panics(t, func() {
+		AdaptiveLimit(WithKeyFunc(KeyByHeader("X-Tenant")), WithCluster(NewMemoryClusterStore(), "pod-1"))
+	})
+}
+
+func TestKeyedLimit(t *testing.T) {
+	var created int
+	handler := KeyedLimit(func() Limiter {
+		created++
+		return NewConcurrencyLimiter(NewAIMD(0), WithInitialLimit(1))
+	}, WithKeyFunc(KeyByHeader("X-Tenant")))
+	assertKeyedLimit(t, handler)
+	assert.Equal(t, 2, created)
+}
+
+type customAlgorithm struct{}
+
+func (customAlgorithm) Update(limit int64, rtt time.Duration, inFlight int64, dropped bool) int64 {
+	return limit
+}
+
+func TestConcurrencyLimitWithKeyFunc(t *testing.T) {
+	assertKeyedLimit(t, ConcurrencyLimit(NewAIMD(0), WithInitialLimit(1), WithKeyFunc(KeyByHeader("X-Tenant"))))
+
+	// each key gets a fresh algorithm
+	for _, algo := range []LimitAlgorithm{NewVegas(), NewGradient2(1.5), NewAIMD(time.Second)} {
+		fresh := algo.(freshAlgorithm).fresh()
+		assert.Equal(t, algo, fresh)
+		assert.NotSame(t, algo, fresh)
+	}
+
+	// other algorithms are shared by the keys, or created by ConcurrencyLimitFunc
+	assertKeyedLimit(t, ConcurrencyLimit(customAlgorithm{}, WithInitialLimit(1), WithKeyFunc(KeyByHeader("X-Tenant"))))
+	var created int
+	assertKeyedLimit(t, ConcurrencyLimitFunc(func() LimitAlgorithm {
+		created++
+		return customAlgorithm{}
+	}, WithInitialLimit(1), WithKeyFunc(KeyByHeader("X-Tenant"))))
+	assert.Equal(t, 2, created)
+	assert.NotPanics(t, func() {
+		ConcurrencyLimit(customAlgorithm{})
+	})
+}
+
+// assertKeyedLimit asserts the handler limiting tenants by X-Tenant to 1 concurrent request
+func assertKeyedLimit(t *testing.T, handler app.HandlerFunc) {
+	var status []int
+	var handlers app.HandlersChain
+	newContext := func(tenant string) *app.RequestContext {
+		ctx := app.NewContext(0)
+		ctx.Request.Header.Set("X-Tenant", tenant)
+		ctx.SetHandlers(handlers)
+		return ctx
+	}
+	handlers = app.HandlersChain{handler, func(c context.Context, ctx *app.RequestContext) {
+		if string(ctx.GetHeader("X-Tenant")) == "a" {
+			// tenant a is limited while tenant b is not affected
+			for _, tenant := range []string{"a", "b"} {
+				inner := newContext(tenant)
+				inner.Next(c)
+				status = append(status, inner.Response.StatusCode())
+			}
+		}
+	}}
+	newContext("a").Next(context.Background())
+	assert.Equal(t, []int{consts.StatusTooManyRequests, consts.StatusOK}, status)
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"container/list"
+	"hash/fnv"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
+
+const keyStoreShards = 32
+
+// KeyStoreStats is the statistics of a KeyStore
+type KeyStoreStats struct {
+	Keys    int   // number of tracked keys
+	Evicted int64 // keys evicted by WithMaxKeys
+	Expired int64 // keys expired by WithKeyTTL
+}
+
+// KeyStore is a sharded in-memory store of per-key limiters,
+// bounded by WithMaxKeys with LRU eviction and WithKeyTTL for idle keys.
+// Idle keys are removed when their shard is accessed, and by the background janitor once Start is called,
+// call Close to stop it. Evicted and expired values implementing Close, e.g. *BBR, are closed.
+type KeyStore struct {
+	shards   [keyStoreShards]*keyShard
+	newValue func() interface{}
+	maxKeys  int64
+	ttl      time.Duration
+	clock    utils.Clock
+	keys     int64
+	evicted  int64
+	expired  int64
+
+	janitorLock sync.Mutex
+	stop        chan struct{} // closes to stop the janitor, nil if not started
+	janitorDone sync.WaitGroup
+}
+
+type keyShard struct {
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used
+}
+
+type keyEntry struct {
+	key        string
+	value      interface{}
+	lastAccess time.Time
+}
+
+// NewKeyStore returns a KeyStore, newLimiter is called for each new key
+func NewKeyStore(newLimiter func() Limiter, opts ...Option) *KeyStore {
+	return newKeyStore(func() interface{} { return newLimiter() }, opts...)
+}
+
+// newKeyStore returns a KeyStore holding values other than limiters
+func newKeyStore(newValue func() interface{}, opts ...Option) *KeyStore {
+	opt := NewOption(opts...)
+	maxKeys := int64(opt.MaxKeys)
+	if maxKeys < 1 {
+		maxKeys = 1
+	}
+	s := &KeyStore{
+		newValue: newValue,
+		maxKeys:  maxKeys,
+		ttl:      opt.KeyTTL,
+		clock:    opt.Clock,
+	}
+	for i := range s.shards {
+		s.shards[i] = &keyShard{
+			entries: make(map[string]*list.Element),
+			lru:     list.New(),
+		}
+	}
+	return s
+}
+
+func (s *KeyStore) shardIndex(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % keyStoreShards)
+}
+
+func (s *KeyStore) shard(key string) *keyShard {
+	return s.shards[s.shardIndex(key)]
+}
+
+// Get returns the limiter of key, a new limiter is created if absent
+func (s *KeyStore) Get(key string) Limiter {
+	return s.load(key).(Limiter)
+}
+
+func (s *KeyStore) load(key string) interface{} {
+	i := s.shardIndex(key)
+	shard := s.shards[i]
+	now := s.clock.Now()
+	shard.lock.Lock()
+	removed := s.expire(shard, now)
+	if elem, ok := shard.entries[key]; ok {
+		entry := elem.Value.(*keyEntry)
+		entry.lastAccess = now
+		shard.lru.MoveToFront(elem)
+		shard.lock.Unlock()
+		closeValues(removed)
+		return entry.value
+	}
+	entry := &keyEntry{key: key, value: s.newValue(), lastAccess: now}
+	shard.entries[key] = shard.lru.PushFront(entry)
+	atomic.AddInt64(&s.keys, 1)
+	shard.lock.Unlock()
+	closeValues(removed)
+	if atomic.LoadInt64(&s.keys) > s.maxKeys {
+		s.evict(i)
+	}
+	return entry.value
+}
+
+// evict removes the least recently used keys of the shards in turn from shard i,
+// until at most maxKeys keys are left. So the eviction is LRU within a shard,
+// and the most recently used key of shard i is kept.
+func (s *KeyStore) evict(i int) {
+	for n := 0; n < keyStoreShards && atomic.LoadInt64(&s.keys) > s.maxKeys; n++ {
+		keep := 0
+		if n == 0 {
+			keep = 1
+		}
+		shard := s.shards[(i+n)%keyStoreShards]
+		var removed []interface{}
+		shard.lock.Lock()
+		for shard.lru.Len() > keep && atomic.LoadInt64(&s.keys) > s.maxKeys {
+			removed = append(removed, s.remove(shard, shard.lru.Back()))
+			atomic.AddInt64(&s.evicted, 1)
+		}
+		shard.lock.Unlock()
+		closeValues(removed)
+	}
+}
+
+// Stats returns the statistics of the store, expired keys are removed first
+func (s *KeyStore) Stats() KeyStoreStats {
+	s.removeExpired()
+	return KeyStoreStats{
+		Keys:    int(atomic.LoadInt64(&s.keys)),
+		Evicted: atomic.LoadInt64(&s.evicted),
+		Expired: atomic.LoadInt64(&s.expired),
+	}
+}
+
+// Start starts the background janitor removing idle keys every half of WithKeyTTL,
+// so keys of shards no longer accessed are released too. It does nothing without WithKeyTTL or if started.
+func (s *KeyStore) Start() {
+	s.janitorLock.Lock()
+	defer s.janitorLock.Unlock()
+	if s.ttl <= 0 || s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.janitorDone.Add(1)
+	go s.janitor(s.stop)
+}
+
+// janitor removes idle keys every half of the ttl until stop is closed
+func (s *KeyStore) janitor(stop chan struct{}) {
+	defer s.janitorDone.Done()
+	ticker := time.NewTicker(s.ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.removeExpired()
+		}
+	}
+}
+
+// Close stops the janitor, removes all keys and closes their values.
+// The store stays usable and Start may be called again.
+func (s *KeyStore) Close() {
+	s.janitorLock.Lock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.janitorLock.Unlock()
+	s.janitorDone.Wait()
+	for _, shard := range s.shards {
+		shard.lock.Lock()
+		var removed []interface{}
+		for elem := shard.lru.Back(); elem != nil; elem = shard.lru.Back() {
+			removed = append(removed, s.remove(shard, elem))
+		}
+		shard.lock.Unlock()
+		closeValues(removed)
+	}
+}
+
+// removeExpired removes keys idle longer than ttl from all shards
+func (s *KeyStore) removeExpired() {
+	if s.ttl <= 0 {
+		return
+	}
+	now := s.clock.Now()
+	for _, shard := range s.shards {
+		shard.lock.Lock()
+		removed := s.expire(shard, now)
+		shard.lock.Unlock()
+		closeValues(removed)
+	}
+}
+
+// expire removes keys of shard idle longer than ttl, starting from the least recently used,
+// it must be called with lock held and returns the removed values
+func (s *KeyStore) expire(shard *keyShard, now time.Time) (removed []interface{}) {
+	if s.ttl <= 0 {
+		return nil
+	}
+	deadline := now.Add(-s.ttl)
+	for elem := shard.lru.Back(); elem != nil; elem = shard.lru.Back() {
+		if elem.Value.(*keyEntry).lastAccess.After(deadline) {
+			break
+		}
+		removed = append(removed, s.remove(shard, elem))
+		atomic.AddInt64(&s.expired, 1)
+	}
+	return removed
+}
+
+// remove must be called with the lock of shard held, it returns the value of the removed entry
+func (s *KeyStore) remove(shard *keyShard, elem *list.Element) interface{} {
+	shard.lru.Remove(elem)
+	entry := elem.Value.(*keyEntry)
+	delete(shard.entries, entry.key)
+	atomic.AddInt64(&s.keys, -1)
+	return entry.value
+}
+
+// closeValues closes the values implementing io.Closer or Close()
+func closeValues(values []interface{}) {
+	for _, v := range values {
+		switch c := v.(type) {
+		case io.Closer:
+			_ = c.Close()
+		case interface{ Close() }:
+			c.Close()
+		}
+	}
+}
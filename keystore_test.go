@@ -0,0 +1,150 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hertz-contrib/limiter/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKeyStore(opts ...Option) *KeyStore {
+	return NewKeyStore(func() Limiter {
+		return NewConcurrencyLimiter(NewVegas())
+	}, opts...)
+}
+
+func TestKeyStoreGet(t *testing.T) {
+	store := newTestKeyStore()
+	defer store.Close()
+	a := store.Get("a")
+	assert.Same(t, a, store.Get("a"))
+	assert.NotSame(t, a, store.Get("b"))
+	assert.Equal(t, 2, store.Stats().Keys)
+}
+
+func TestKeyStoreEvict(t *testing.T) {
+	store := newTestKeyStore(WithMaxKeys(keyStoreShards))
+	defer store.Close()
+	for i := 0; i < 1000; i++ {
+		store.Get(strconv.Itoa(i))
+	}
+	stats := store.Stats()
+	assert.Equal(t, keyStoreShards, stats.Keys)
+	assert.Equal(t, int64(1000-keyStoreShards), stats.Evicted)
+
+	// the most recently used key is kept
+	shard := store.shard("999")
+	shard.lock.Lock()
+	_, ok := shard.entries["999"]
+	shard.lock.Unlock()
+	assert.True(t, ok)
+}
+
+func TestKeyStoreMaxKeys(t *testing.T) {
+	// fewer keys than shards
+	store := newTestKeyStore(WithMaxKeys(10))
+	defer store.Close()
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		a := store.Get(key)
+		// the new key is kept
+		assert.Same(t, a, store.Get(key))
+		assert.LessOrEqual(t, store.Stats().Keys, 10)
+	}
+	assert.Equal(t, KeyStoreStats{Keys: 10, Evicted: 990}, store.Stats())
+}
+
+func TestKeyStoreJanitor(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	store := newTestKeyStore(WithClock(clock), WithKeyTTL(20*time.Millisecond))
+	store.Start()
+	store.Start()
+	store.Get("a")
+	clock.Advance(time.Second)
+	// removed without accessing the store
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&store.keys) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	// stopped by Close, and started again
+	store.Close()
+	assert.Nil(t, store.stop)
+	store.Get("a")
+	clock.Advance(time.Second)
+	store.Start()
+	defer store.Close()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&store.keys) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestKeyStoreExpire(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	store := newTestKeyStore(WithClock(clock), WithKeyTTL(200*time.Millisecond))
+	a := store.Get("a")
+	clock.Advance(150 * time.Millisecond)
+	store.Get("b")
+	store.removeExpired()
+	assert.Equal(t, 2, store.Stats().Keys)
+	clock.Advance(100 * time.Millisecond)
+	assert.Equal(t, KeyStoreStats{Keys: 1, Expired: 1}, store.Stats())
+
+	// expired keys are removed on access and recreated
+	clock.Advance(200 * time.Millisecond)
+	assert.NotSame(t, a, store.Get("a"))
+	assert.Equal(t, KeyStoreStats{Keys: 1, Expired: 2}, store.Stats())
+	store.Close()
+	assert.Equal(t, 0, store.Stats().Keys)
+}
+
+type closeCounter struct {
+	closed int
+}
+
+func (c *closeCounter) Close() {
+	c.closed++
+}
+
+func TestKeyStoreCloseValues(t *testing.T) {
+	var values []*closeCounter
+	store := newKeyStore(func() interface{} {
+		v := new(closeCounter)
+		values = append(values, v)
+		return v
+	}, WithMaxKeys(keyStoreShards))
+	for i := 0; i < 100; i++ {
+		store.load(strconv.Itoa(i))
+	}
+	stats := store.Stats()
+	var closed int
+	for _, v := range values {
+		closed += v.closed
+	}
+	assert.Equal(t, int(stats.Evicted), closed)
+
+	// Close closes the remaining values once
+	store.Close()
+	store.Close()
+	for _, v := range values {
+		assert.Equal(t, 1, v.closed)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package kitex provides the kitex server middleware of the limiters,
+// it's a separate module to keep the kitex dependency optional.
+package kitex
+
+import (
+	"context"
+
+	"github.com/cloudwego/kitex/pkg/endpoint"
+	"github.com/cloudwego/kitex/pkg/kerrors"
+
+	"github.com/hertz-contrib/limiter"
+)
+
+// ErrCode is the biz status code of rejected requests
+const ErrCode = 429
+
+// AdaptiveLimit CPU sampling algorithm using BBR
+func AdaptiveLimit(opts ...limiter.Option) endpoint.Middleware {
+	return LimitMiddleware(limiter.NewLimiter(opts...))
+}
+
+// LimitMiddleware returns the kitex server middleware using an existing limiter,
+// a *limiter.BBR can be shared with limiter.LimitHandler to apply one overload policy
+// across Hertz and Kitex. Rejected requests get a biz status error with ErrCode.
+func LimitMiddleware(l limiter.Limiter) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, req, resp interface{}) error {
+			done, err := l.Allow()
+			if err != nil {
+				return kerrors.NewBizStatusError(ErrCode, err.Error())
+			}
+			defer done()
+			return next(ctx, req, resp)
+		}
+	}
+}
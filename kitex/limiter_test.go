@@ -0,0 +1,45 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kitex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/kitex/pkg/kerrors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hertz-contrib/limiter"
+)
+
+func TestLimitMiddleware(t *testing.T) {
+	l := limiter.NewConcurrencyLimiter(limiter.NewAIMD(0), limiter.WithInitialLimit(1))
+	var inner error
+	var ep func(ctx context.Context, req, resp interface{}) error
+	ep = LimitMiddleware(l)(func(ctx context.Context, req, resp interface{}) error {
+		if req != nil {
+			inner = ep(ctx, nil, resp)
+		}
+		return nil
+	})
+	assert.Nil(t, ep(context.Background(), struct{}{}, nil))
+
+	bizErr, ok := kerrors.FromBizStatusError(inner)
+	assert.True(t, ok)
+	assert.Equal(t, int32(ErrCode), bizErr.BizStatusCode())
+	assert.Equal(t, limiter.ErrLimit, bizErr.BizMessage())
+}
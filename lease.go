@@ -0,0 +1,164 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
+
+const leaseBuckets = 10
+
+// LeaseStore leases batches of tokens from a shared Store and serves them locally
+// until the lease is exhausted or expired, so that most requests don't reach the shared store.
+// The lease size is the local rate per lease duration observed in the last 10 lease durations,
+// bounded by WithMaxLease and the burst of the rate.
+// Leased tokens left unused when the lease expires are not returned, so the shared quota
+// may be underused by up to one lease per instance and key.
+type LeaseStore struct {
+	shared        Store
+	keys          *KeyStore
+	leaseDuration time.Duration
+	maxLease      int64
+}
+
+// lease is the local state of one key
+type lease struct {
+	lock   sync.Mutex
+	tokens int64
+	expire time.Time
+	stat   *utils.RollingWindow // taken tokens
+	refill *refill              // in-flight request to the shared store
+}
+
+// refill is a request to the shared store, other requests of the key wait for it
+type refill struct {
+	done chan struct{}
+	ok   bool
+	err  error
+}
+
+// NewLeaseStore returns a LeaseStore leasing from shared, keys are bounded by WithMaxKeys and WithKeyTTL
+func NewLeaseStore(shared Store, opts ...Option) *LeaseStore {
+	opt := NewOption(opts...)
+	s := &LeaseStore{
+		shared:        shared,
+		leaseDuration: opt.LeaseDuration,
+		maxLease:      opt.MaxLease,
+	}
+	s.keys = newKeyStore(func() interface{} {
+		return &lease{stat: utils.NewRollingWindow(leaseBuckets, s.leaseDuration)}
+	}, opts...)
+	s.keys.Start()
+	return s
+}
+
+// Close removes the leases of all keys and stops expiring them, the shared store is not closed
+func (s *LeaseStore) Close() {
+	s.keys.Close()
+}
+
+// Take implements Store, the shared store is only reached when a new lease is needed.
+// Only one request per key reaches it at a time, the others wait for the new lease.
+func (s *LeaseStore) Take(ctx context.Context, key string, algorithm Algorithm, rate Rate, n int64) (bool, error) {
+	l := s.keys.load(key).(*lease)
+	l.lock.Lock()
+	l.stat.Add(float64(n))
+	for {
+		if l.tokens >= n && time.Now().Before(l.expire) {
+			l.tokens -= n
+			l.lock.Unlock()
+			return true, nil
+		}
+		r := l.refill
+		if r == nil {
+			break
+		}
+		l.lock.Unlock()
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		if r.err != nil || !r.ok {
+			// the shared quota is exhausted or unreachable for the waiters as well
+			return false, r.err
+		}
+		l.lock.Lock()
+	}
+
+	r := &refill{done: make(chan struct{})}
+	l.refill = r
+	size := s.leaseSize(l, rate, n)
+	l.lock.Unlock()
+
+	size, r.ok, r.err = s.lease(ctx, key, algorithm, rate, size, n)
+
+	l.lock.Lock()
+	l.refill = nil
+	if r.ok {
+		now := time.Now()
+		if !now.Before(l.expire) {
+			l.tokens = 0
+		}
+		l.tokens += size - n
+		l.expire = now.Add(s.leaseDuration)
+	}
+	l.lock.Unlock()
+	close(r.done)
+	return r.ok, r.err
+}
+
+// lease takes size tokens from the shared store, or only n if the whole lease isn't available
+func (s *LeaseStore) lease(ctx context.Context, key string, algorithm Algorithm, rate Rate, size, n int64) (int64, bool, error) {
+	ok, err := s.shared.Take(ctx, key, algorithm, rate, size)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok && size > n {
+		// not enough tokens for the whole lease, take what this request needs
+		size = n
+		ok, err = s.shared.Take(ctx, key, algorithm, rate, size)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+	return size, ok, nil
+}
+
+// leaseSize estimates the tokens needed in the next lease duration by the local rate
+func (s *LeaseStore) leaseSize(l *lease, rate Rate, n int64) int64 {
+	var taken, buckets float64
+	l.stat.Reduce(func(b *utils.Bucket) {
+		if b.Count > 0 {
+			taken += b.Sum
+			buckets++
+		}
+	})
+	size := int64(math.Ceil(taken / math.Max(buckets, 1)))
+	if max := int64(math.Min(float64(s.maxLease), float64(rate.burst()))); size > max {
+		size = max
+	}
+	if size < n {
+		size = n
+	}
+	return size
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStore counts the calls reaching the shared store
+type countingStore struct {
+	Store
+	calls int
+}
+
+func (s *countingStore) Take(ctx context.Context, key string, algorithm Algorithm, rate Rate, n int64) (bool, error) {
+	s.calls++
+	return s.Store.Take(ctx, key, algorithm, rate, n)
+}
+
+func TestLeaseStore(t *testing.T) {
+	shared := &countingStore{Store: NewLocalStore()}
+	s := NewLeaseStore(shared, WithLeaseDuration(time.Hour), WithMaxLease(10))
+	defer s.Close()
+	rate := Rate{Limit: 1000, Period: time.Hour, Burst: 100}
+
+	// the lease grows with the local rate
+	assert.Equal(t, 100, takeLocal(t, s, TokenBucket, rate, 100))
+	assert.Less(t, shared.calls, 30)
+
+	// the shared quota is exhausted, leases can't exceed it
+	assert.Equal(t, 0, takeLocal(t, s, TokenBucket, rate, 10))
+}
+
+func TestLeaseStoreExpire(t *testing.T) {
+	shared := &countingStore{Store: NewLocalStore()}
+	s := NewLeaseStore(shared, WithLeaseDuration(20*time.Millisecond))
+	defer s.Close()
+	rate := Rate{Limit: 1000, Period: time.Hour, Burst: 1000}
+	assert.Equal(t, 50, takeLocal(t, s, TokenBucket, rate, 50))
+	calls := shared.calls
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, takeLocal(t, s, TokenBucket, rate, 1))
+	assert.Equal(t, calls+1, shared.calls)
+}
+
+// blockingStore blocks the calls reaching the shared store until released
+type blockingStore struct {
+	Store
+	calls   int32
+	release chan struct{}
+}
+
+func (s *blockingStore) Take(ctx context.Context, key string, algorithm Algorithm, rate Rate, n int64) (bool, error) {
+	atomic.AddInt32(&s.calls, 1)
+	<-s.release
+	return s.Store.Take(ctx, key, algorithm, rate, n)
+}
+
+func TestLeaseStoreSingleFlight(t *testing.T) {
+	shared := &blockingStore{Store: NewLocalStore(), release: make(chan struct{})}
+	s := NewLeaseStore(shared, WithLeaseDuration(time.Hour))
+	defer s.Close()
+	rate := Rate{Limit: 1000, Period: time.Hour, Burst: 1000}
+
+	var wg sync.WaitGroup
+	var allowed int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, err := s.Take(context.Background(), "key", TokenBucket, rate, 1); ok && err == nil {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	for atomic.LoadInt32(&shared.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the others wait without holding the lock of the key
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := s.Take(ctx, "key", TokenBucket, rate, 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&shared.calls))
+
+	close(shared.release)
+	wg.Wait()
+	assert.Equal(t, int32(10), allowed)
+	assert.Less(t, atomic.LoadInt32(&shared.calls), int32(10))
+}
+
+func TestLeaseStoreSingleFlightRejected(t *testing.T) {
+	shared := &blockingStore{Store: NewLocalStore(), release: make(chan struct{})}
+	s := NewLeaseStore(shared, WithLeaseDuration(time.Hour))
+	defer s.Close()
+	rate := Rate{Limit: 1, Period: time.Hour}
+	close(shared.release)
+	assert.Equal(t, 1, takeLocal(t, s, TokenBucket, rate, 1))
+
+	// the waiters share the rejection of the refill
+	shared.release = make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := s.Take(context.Background(), "key", TokenBucket, rate, 1)
+			assert.False(t, ok)
+			assert.Nil(t, err)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(shared.release)
+	wg.Wait()
+	// one refill of the lease, then of the request alone
+	assert.LessOrEqual(t, atomic.LoadInt32(&shared.calls), int32(3))
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"math"
+	"os"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	gMem int64
+	// gCgroupLimit is the cgroup memory limit in bytes, 0 if unlimited
+	gCgroupLimit = readCgroupLimit("/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes")
+)
+
+// readCgroupLimit reads the memory limit of cgroup v2 or v1
+func readCgroupLimit(paths ...string) uint64 {
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		// "max" of v2 or a huge number of v1 means unlimited
+		if err != nil || limit >= math.MaxInt64/2 {
+			return 0
+		}
+		return limit
+	}
+	return 0
+}
+
+// memoryUsage returns the memory used by the go runtime against GOMEMLIMIT or cgroupLimit,
+// e.g. 800 means 80%. It returns 0 if neither limit is set.
+// The memory used is the same as GOMEMLIMIT accounts: all memory mapped by the go runtime minus the released.
+func memoryUsage(cgroupLimit uint64) int64 {
+	memSamples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+		{Name: "/gc/gomemlimit:bytes"},
+	}
+	metrics.Read(memSamples)
+	if memSamples[0].Value.Kind() != metrics.KindUint64 || memSamples[1].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	used := memSamples[0].Value.Uint64() - memSamples[1].Value.Uint64()
+	limit := cgroupLimit
+	// gomemlimit is supported since go 1.21
+	if memSamples[2].Value.Kind() == metrics.KindUint64 {
+		if memLimit := memSamples[2].Value.Uint64(); memLimit < math.MaxInt64 && (limit == 0 || memLimit < limit) {
+			limit = memLimit
+		}
+	}
+	if limit == 0 {
+		return 0
+	}
+	return int64(float64(used) / float64(limit) * 1000)
+}
+
+// memProc samples memory usage, it's called on the ticker of systemProc
+func memProc() {
+	atomic.StoreInt64(&gMem, memoryUsage(gCgroupLimit))
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hertz-contrib/limiter/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadCgroupLimit(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+	assert.Equal(t, uint64(0), readCgroupLimit(filepath.Join(dir, "missing")))
+	assert.Equal(t, uint64(1<<30), readCgroupLimit(filepath.Join(dir, "missing"), write("v2", "1073741824\n")))
+	assert.Equal(t, uint64(0), readCgroupLimit(write("max", "max\n")))
+	assert.Equal(t, uint64(0), readCgroupLimit(write("v1", "9223372036854771712\n")))
+}
+
+func TestMemoryUsage(t *testing.T) {
+	usage := memoryUsage(1 << 50)
+	assert.GreaterOrEqual(t, usage, int64(0))
+	assert.Less(t, usage, int64(1000))
+}
+
+func TestBBRShouldDropByMemory(t *testing.T) {
+	var mem int64
+	cpu := int64(1000)
+	bbr := newDropTestLimiter(utils.NewFakeClock(time.Now()), &cpu, WithMemoryThreshold(900), WithoutCPU())
+	bbr.memory = func() int64 {
+		return mem
+	}
+
+	// cpu is ignored
+	mem = 800
+	assert.Equal(t, false, bbr.shouldDrop())
+	mem = 950
+	assert.Equal(t, true, bbr.shouldDrop())
+
+	// memory alongside cpu
+	assert.Nil(t, bbr.Update(func(o *options) {
+		o.DisableCPU = false
+	}))
+	mem = 0
+	assert.Equal(t, true, bbr.overloaded())
+}
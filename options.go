@@ -16,7 +16,15 @@
 
 package limiter
 
-import "time"
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
 
 type Option func(o *options)
 
@@ -26,14 +34,103 @@ var opt = options{
 	CPUThreshold: 800,                    // CPU load  80%
 	SamplingTime: 500 * time.Millisecond, //
 	Decay:        0.95,                   //
+	InitialLimit: 20,
+	MinLimit:     1,
+	MaxLimit:     1000,
+	K:            2,
+
+	ErrorRateThreshold:    0.5,
+	SlowCallRateThreshold: 0.5,
+	SlowCallDuration:      time.Second,
+	MinRequests:           20,
+	OpenDuration:          5 * time.Second,
+	HalfOpenRequests:      5,
+
+	MaxKeys: 65536,
+	KeyTTL:  10 * time.Minute,
+
+	RateAlgorithm: TokenBucket,
+	Fallback:      FallbackLocal,
+
+	LeaseDuration: time.Second,
+	MaxLease:      100,
+
+	ClusterInterval: time.Second,
+	ClusterWeight:   0.5,
+
+	Clock: utils.SystemClock,
+
+	MinDropDuration: time.Second,
+	RTUnit:          time.Millisecond,
 }
 
 type options struct {
-	Window       time.Duration
-	Bucket       int
-	CPUThreshold int64
-	SamplingTime time.Duration
-	Decay        float64
+	Window       time.Duration `yaml:"window" json:"window"`
+	Bucket       int           `yaml:"bucket" json:"bucket"`
+	CPUThreshold int64         `yaml:"cpu_threshold" json:"cpu_threshold"`
+	SamplingTime time.Duration `yaml:"sampling_time" json:"sampling_time"`
+	Decay        float64       `yaml:"decay" json:"decay"`
+	InitialLimit int64         `yaml:"initial_limit" json:"initial_limit"`
+	MinLimit     int64         `yaml:"min_limit" json:"min_limit"`
+	MaxLimit     int64         `yaml:"max_limit" json:"max_limit"`
+	K            float64       `yaml:"k" json:"k"`
+
+	ErrorRateThreshold    float64                     `yaml:"error_rate_threshold" json:"error_rate_threshold"`
+	SlowCallRateThreshold float64                     `yaml:"slow_call_rate_threshold" json:"slow_call_rate_threshold"`
+	SlowCallDuration      time.Duration               `yaml:"slow_call_duration" json:"slow_call_duration"`
+	MinRequests           int64                       `yaml:"min_requests" json:"min_requests"`
+	OpenDuration          time.Duration               `yaml:"open_duration" json:"open_duration"`
+	HalfOpenRequests      int64                       `yaml:"half_open_requests" json:"half_open_requests"`
+	OnStateChange         func(from, to BreakerState) `yaml:"-" json:"-"`
+
+	KeyFunc func(c context.Context, ctx *app.RequestContext) string `yaml:"-" json:"-"`
+	MaxKeys int                                                     `yaml:"max_keys" json:"max_keys"`
+	KeyTTL  time.Duration                                           `yaml:"key_ttl" json:"key_ttl"`
+
+	RateAlgorithm Algorithm      `yaml:"rate_algorithm" json:"rate_algorithm"`
+	Fallback      FallbackPolicy `yaml:"fallback" json:"fallback"`
+
+	LeaseDuration time.Duration `yaml:"lease_duration" json:"lease_duration"`
+	MaxLease      int64         `yaml:"max_lease" json:"max_lease"`
+
+	ClusterStore    ClusterStore  `yaml:"-" json:"-"`
+	ClusterInstance string        `yaml:"-" json:"-"`
+	ClusterInterval time.Duration `yaml:"cluster_interval" json:"cluster_interval"`
+	ClusterWeight   float64       `yaml:"cluster_weight" json:"cluster_weight"`
+
+	MemoryThreshold int64 `yaml:"memory_threshold" json:"memory_threshold"`
+	DisableCPU      bool  `yaml:"disable_cpu" json:"disable_cpu"`
+
+	SchedLatencyThreshold time.Duration `yaml:"sched_latency_threshold" json:"sched_latency_threshold"`
+	GoroutineThreshold    int64         `yaml:"goroutine_threshold" json:"goroutine_threshold"`
+
+	OverloadDetector OverloadDetector `yaml:"-" json:"-"`
+
+	RTQuantile float64 `yaml:"rt_quantile" json:"rt_quantile"`
+	Shards     int     `yaml:"shards" json:"shards"`
+
+	Clock utils.Clock `yaml:"-" json:"-"`
+
+	CoolDown         time.Duration `yaml:"cool_down" json:"cool_down"`
+	MinDropDuration  time.Duration `yaml:"min_drop_duration" json:"min_drop_duration"`
+	CPUExitThreshold int64         `yaml:"cpu_exit_threshold" json:"cpu_exit_threshold"`
+
+	DropSpan float64 `yaml:"drop_span" json:"drop_span"`
+
+	WarmUp         time.Duration `yaml:"warm_up" json:"warm_up"`
+	WarmUpCapacity int64         `yaml:"warm_up_capacity" json:"warm_up_capacity"`
+
+	RTUnit time.Duration `yaml:"rt_unit" json:"rt_unit"`
+
+	LoadThreshold  float64       `yaml:"load_threshold" json:"load_threshold"`
+	AvgRTThreshold time.Duration `yaml:"avg_rt_threshold" json:"avg_rt_threshold"`
+	QPSThreshold   int64         `yaml:"qps_threshold" json:"qps_threshold"`
+	MaxConcurrency int64         `yaml:"max_concurrency" json:"max_concurrency"`
+
+	// samplingTimeSet and decaySet report whether SamplingTime and Decay were set explicitly,
+	// only those are applied to the shared cpu sampler
+	samplingTimeSet bool
+	decaySet        bool
 }
 
 // WithWindow defines time duration per window
@@ -58,23 +155,347 @@ func WithCPUThreshold(threshold int64) Option {
 	}
 }
 
-// WithSamplingTime defines cpu sampling time interval
+// WithSamplingTime defines cpu sampling time interval. The cpu sampler is shared by all limiters,
+// so the last BBR created or updated with it wins.
 func WithSamplingTime(samplingTime time.Duration) Option {
 	return func(o *options) {
 		o.SamplingTime = samplingTime
+		o.samplingTimeSet = true
 	}
 }
 
-// WithDecay defines cpu attenuation factor
+// WithDecay defines cpu attenuation factor, shared by all limiters like WithSamplingTime
 func WithDecay(decay float64) Option {
 	return func(o *options) {
 		o.Decay = decay
+		o.decaySet = true
+	}
+}
+
+// WithInitialLimit defines the concurrency limit a ConcurrencyLimiter starts with
+func WithInitialLimit(limit int64) Option {
+	return func(o *options) {
+		o.InitialLimit = limit
+	}
+}
+
+// WithMinLimit defines the lower bound of the concurrency limit
+func WithMinLimit(limit int64) Option {
+	return func(o *options) {
+		o.MinLimit = limit
+	}
+}
+
+// WithMaxLimit defines the upper bound of the concurrency limit
+func WithMaxLimit(limit int64) Option {
+	return func(o *options) {
+		o.MaxLimit = limit
+	}
+}
+
+// WithK defines the multiplier of accepts for the client side throttling,
+// lower values make the throttler more aggressive. e.g. 2 allows requests up to twice of the accepts.
+func WithK(k float64) Option {
+	return func(o *options) {
+		o.K = k
+	}
+}
+
+// WithErrorRateThreshold defines the error rate opening the circuit breaker, e.g. 0.5 for 50%
+func WithErrorRateThreshold(rate float64) Option {
+	return func(o *options) {
+		o.ErrorRateThreshold = rate
+	}
+}
+
+// WithSlowCallRateThreshold defines the slow call rate opening the circuit breaker, e.g. 0.5 for 50%
+func WithSlowCallRateThreshold(rate float64) Option {
+	return func(o *options) {
+		o.SlowCallRateThreshold = rate
+	}
+}
+
+// WithSlowCallDuration defines calls slower than which are counted as slow calls
+func WithSlowCallDuration(duration time.Duration) Option {
+	return func(o *options) {
+		o.SlowCallDuration = duration
+	}
+}
+
+// WithMinRequests defines the minimum calls in the window before the circuit breaker can open
+func WithMinRequests(requests int64) Option {
+	return func(o *options) {
+		o.MinRequests = requests
+	}
+}
+
+// WithOpenDuration defines how long the circuit breaker stays open before half-open
+func WithOpenDuration(duration time.Duration) Option {
+	return func(o *options) {
+		o.OpenDuration = duration
+	}
+}
+
+// WithHalfOpenRequests defines the number of probe calls permitted in half-open state,
+// the circuit breaker closes after all of them succeed.
+func WithHalfOpenRequests(requests int64) Option {
+	return func(o *options) {
+		o.HalfOpenRequests = requests
 	}
 }
 
+// WithStateChangeHook defines the hook called after the circuit breaker state changed
+func WithStateChangeHook(hook func(from, to BreakerState)) Option {
+	return func(o *options) {
+		o.OnStateChange = hook
+	}
+}
+
+// WithKeyFunc defines how to extract the key of a request, requests with different keys
+// are limited by different limiters, e.g. KeyByClientIP, KeyByHeader, KeyByParam and KeyByQuery.
+// It's used by AdaptiveLimit and KeyedLimit.
+func WithKeyFunc(keyFunc func(c context.Context, ctx *app.RequestContext) string) Option {
+	return func(o *options) {
+		o.KeyFunc = keyFunc
+	}
+}
+
+// WithMaxKeys defines the maximum number of keys tracked by a KeyStore,
+// the least recently used key of a shard is evicted when exceeded.
+func WithMaxKeys(maxKeys int) Option {
+	return func(o *options) {
+		o.MaxKeys = maxKeys
+	}
+}
+
+// WithKeyTTL defines how long an idle key is kept by a KeyStore, 0 disables the expiration
+func WithKeyTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.KeyTTL = ttl
+	}
+}
+
+// WithRateAlgorithm defines the algorithm of a RateLimiter, e.g. TokenBucket, SlidingWindow or GCRA
+func WithRateAlgorithm(algorithm Algorithm) Option {
+	return func(o *options) {
+		o.RateAlgorithm = algorithm
+	}
+}
+
+// WithFallback defines what a RateLimiter does when its store is unreachable
+func WithFallback(policy FallbackPolicy) Option {
+	return func(o *options) {
+		o.Fallback = policy
+	}
+}
+
+// WithLeaseDuration defines how long the tokens leased by a LeaseStore are valid,
+// it's also the duration the lease size is estimated for.
+func WithLeaseDuration(duration time.Duration) Option {
+	return func(o *options) {
+		o.LeaseDuration = duration
+	}
+}
+
+// WithMaxLease defines the maximum number of tokens leased at once by a LeaseStore
+func WithMaxLease(lease int64) Option {
+	return func(o *options) {
+		o.MaxLease = lease
+	}
+}
+
+// WithCluster lets BBR publish its window summary as instance to store,
+// and blend the cluster wide capacity estimate into maxInFlight.
+// Each BBR publishes on its own, so it can't be used with WithKeyFunc.
+func WithCluster(store ClusterStore, instance string) Option {
+	return func(o *options) {
+		o.ClusterStore = store
+		o.ClusterInstance = instance
+	}
+}
+
+// WithClusterInterval defines how often BBR publishes and fetches window summaries,
+// summaries older than 3 intervals are ignored.
+func WithClusterInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.ClusterInterval = interval
+	}
+}
+
+// WithClusterWeight defines the weight of the cluster estimate in maxInFlight,
+// 0 uses the local estimate only, 1 uses the cluster estimate only.
+func WithClusterWeight(weight float64) Option {
+	return func(o *options) {
+		o.ClusterWeight = weight
+	}
+}
+
+// WithMemoryThreshold defines memory usage threshold against GOMEMLIMIT or the cgroup memory limit,
+// which starts dropping alongside the CPU threshold. e.g. 900 for 90%, 0 disables it.
+func WithMemoryThreshold(threshold int64) Option {
+	return func(o *options) {
+		o.MemoryThreshold = threshold
+	}
+}
+
+// WithoutCPU disables the CPU threshold, e.g. to start dropping by memory usage only
+func WithoutCPU() Option {
+	return func(o *options) {
+		o.DisableCPU = true
+	}
+}
+
+// WithSchedLatencyThreshold defines the threshold of p99 go scheduling latency during the last sampling time,
+// which reacts to runqueue saturation before the CPU load does, 0 disables it.
+func WithSchedLatencyThreshold(latency time.Duration) Option {
+	return func(o *options) {
+		o.SchedLatencyThreshold = latency
+	}
+}
+
+// WithGoroutineThreshold defines the threshold of the goroutine count, 0 disables it
+func WithGoroutineThreshold(goroutines int64) Option {
+	return func(o *options) {
+		o.GoroutineThreshold = goroutines
+	}
+}
+
+// WithOverloadDetector defines when BBR is overloaded and starts dropping, it replaces the CPU, memory,
+// scheduling latency and goroutine thresholds, while the system rules of the limiter still apply.
+// Implement HysteresisDetector and PressureDetector for the hysteresis and WithDropSpan.
+func WithOverloadDetector(detector OverloadDetector) Option {
+	return func(o *options) {
+		o.OverloadDetector = detector
+	}
+}
+
+// WithRTQuantile lets BBR record the RT distribution of each bucket, and use the q-quantile RT
+// instead of the mean when finding the min RT, e.g. 0.5 for p50. 0 disables it.
+func WithRTQuantile(q float64) Option {
+	return func(o *options) {
+		o.RTQuantile = q
+	}
+}
+
+// WithShards spreads the window of BBR over shards to reduce the lock contention at high QPS,
+// e.g. runtime.GOMAXPROCS(0). It can't be updated.
+func WithShards(shards int) Option {
+	return func(o *options) {
+		o.Shards = shards
+	}
+}
+
+// WithClock lets BBR, ConcurrencyLimiter, Breaker, KeyStore and their windows tell the time by clock, e.g. a utils.FakeClock in tests. It can't be updated.
+func WithClock(clock utils.Clock) Option {
+	return func(o *options) {
+		o.Clock = clock
+	}
+}
+
+// WithCoolDown defines how long BBR keeps dropping requests exceeding the capacity after recovered from overload,
+// it is 0 by default.
+func WithCoolDown(coolDown time.Duration) Option {
+	return func(o *options) {
+		o.CoolDown = coolDown
+	}
+}
+
+// WithMinDropDuration defines the minimum duration BBR keeps dropping requests exceeding the capacity once started,
+// it is 1s by default.
+func WithMinDropDuration(d time.Duration) Option {
+	return func(o *options) {
+		o.MinDropDuration = d
+	}
+}
+
+// WithCPUExitThreshold defines the CPU threshold to recover from overload once dropping, e.g. 700 with
+// WithCPUThreshold(800), so the CPU load around 800 doesn't flap the dropping on and off. 0 means CPUThreshold.
+func WithCPUExitThreshold(threshold int64) Option {
+	return func(o *options) {
+		o.CPUExitThreshold = threshold
+	}
+}
+
+// WithDropSpan sheds requests gradually instead of dropping every request exceeding the capacity.
+// The drop probability grows linearly to 1 when the inflight count exceeds the capacity by span,
+// e.g. 0.5 for 150% of the capacity, and the CPU load over CPUThreshold raises it further. 0 disables it.
+func WithDropSpan(span float64) Option {
+	return func(o *options) {
+		o.DropSpan = span
+	}
+}
+
+// WithWarmUp protects a fresh limiter, whose empty windows estimate the capacity as 1, for d since created.
+// Without capacity, all requests are admitted during d. Otherwise, the capacity is at least the seeded capacity,
+// which fades out linearly during d, while the windows fill up.
+func WithWarmUp(d time.Duration, capacity int64) Option {
+	return func(o *options) {
+		o.WarmUp = d
+		o.WarmUpCapacity = capacity
+	}
+}
+
+// WithRTUnit defines the unit BBR records the response time in, time.Millisecond by default.
+// Use time.Microsecond for sub-millisecond handlers, whose RT would otherwise fall back to the floor of 1ms.
+// It ranges from time.Nanosecond to time.Second, and can't be updated.
+func WithRTUnit(unit time.Duration) Option {
+	return func(o *options) {
+		o.RTUnit = unit
+	}
+}
+
+// WithLoadThreshold defines the load1 threshold read from /proc/loadavg, e.g. the number of cores.
+// Like the other system rules, it starts dropping alongside the CPU threshold, 0 disables it.
+func WithLoadThreshold(load float64) Option {
+	return func(o *options) {
+		o.LoadThreshold = load
+	}
+}
+
+// WithAvgRTThreshold defines the average response time threshold in the window, 0 disables it
+func WithAvgRTThreshold(rt time.Duration) Option {
+	return func(o *options) {
+		o.AvgRTThreshold = rt
+	}
+}
+
+// WithQPSThreshold defines the entry QPS threshold averaged in the window, 0 disables it
+func WithQPSThreshold(qps int64) Option {
+	return func(o *options) {
+		o.QPSThreshold = qps
+	}
+}
+
+// WithMaxConcurrency defines the threshold of concurrent requests, 0 disables it
+func WithMaxConcurrency(concurrency int64) Option {
+	return func(o *options) {
+		o.MaxConcurrency = concurrency
+	}
+}
+
+// NewOption applies opts on a copy of the default options,
+// so that limiters created with different options don't affect each other.
 func NewOption(opts ...Option) options {
+	o := opt
 	for _, apply := range opts {
-		apply(&opt)
+		apply(&o)
+	}
+	return o
+}
+
+// validate returns an error if the options can't drive a BBR limiter
+func (o options) validate() error {
+	if o.Bucket < 1 || o.Window < time.Duration(o.Bucket) {
+		return fmt.Errorf("invalid window %s with %d buckets", o.Window, o.Bucket)
+	}
+	if o.SamplingTime <= 0 {
+		return fmt.Errorf("invalid sampling time %s", o.SamplingTime)
+	}
+	if o.Decay < 0 || o.Decay >= 1 {
+		return fmt.Errorf("invalid decay %v", o.Decay)
+	}
+	if o.RTUnit < time.Nanosecond || o.RTUnit > time.Second {
+		return fmt.Errorf("invalid rt unit %s", o.RTUnit)
 	}
-	return opt
+	return nil
 }
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// FallbackPolicy defines what to do when the store is unreachable
+type FallbackPolicy int
+
+const (
+	// FallbackLocal limits by a LocalStore of this instance
+	FallbackLocal FallbackPolicy = iota
+	// FallbackAllow allows all requests
+	FallbackAllow
+	// FallbackDeny rejects all requests
+	FallbackDeny
+)
+
+func (p FallbackPolicy) String() string {
+	switch p {
+	case FallbackLocal:
+		return "local"
+	case FallbackAllow:
+		return "allow"
+	case FallbackDeny:
+		return "deny"
+	}
+	return "FallbackPolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// RateLimiter limits events of each key to a Rate, its state is kept in a Store,
+// e.g. a redis store to share the quota across instances.
+type RateLimiter struct {
+	store    Store
+	fallback *LocalStore
+	rate     Rate
+	opts     options
+	// unreachable is 1 while the store fails, so only the changes are logged
+	unreachable int32
+}
+
+// NewRateLimiter returns a RateLimiter backed by store, it returns ErrInvalidRate if rate is invalid
+func NewRateLimiter(store Store, rate Rate, opts ...Option) (*RateLimiter, error) {
+	if err := rate.Validate(); err != nil {
+		return nil, err
+	}
+	opt := NewOption(opts...)
+	l := &RateLimiter{
+		store: store,
+		rate:  rate,
+		opts:  opt,
+	}
+	if opt.Fallback == FallbackLocal {
+		l.fallback = NewLocalStore(opts...)
+	}
+	return l, nil
+}
+
+// Close releases the local fallback state, the store is not closed
+func (l *RateLimiter) Close() {
+	if l.fallback != nil {
+		l.fallback.Close()
+	}
+}
+
+// Take returns ErrLimitExceeded if the event of key is not allowed
+func (l *RateLimiter) Take(ctx context.Context, key string) error {
+	ok, err := l.store.Take(ctx, key, l.opts.RateAlgorithm, l.rate, 1)
+	if err == nil {
+		if atomic.CompareAndSwapInt32(&l.unreachable, 1, 0) {
+			hlog.CtxInfof(ctx, "HERTZ: Limiter store recovered")
+		}
+	} else {
+		if atomic.CompareAndSwapInt32(&l.unreachable, 0, 1) {
+			hlog.CtxWarnf(ctx, "HERTZ: Limiter store unreachable, fallback=%s, error=%s", l.opts.Fallback, err.Error())
+		}
+		switch l.opts.Fallback {
+		case FallbackLocal:
+			ok, _ = l.fallback.Take(ctx, key, l.opts.RateAlgorithm, l.rate, 1)
+		case FallbackAllow:
+			ok = true
+		default:
+			ok = false
+		}
+	}
+	if !ok {
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+// Allow implements Limiter, all events share one key
+func (l *RateLimiter) Allow() (func(), error) {
+	if err := l.Take(context.Background(), ""); err != nil {
+		return nil, err
+	}
+	return func() {}, nil
+}
+
+// RateLimit limits requests to rate, the state is kept in store.
+// Requests are limited by the key extracted with WithKeyFunc if set.
+// It panics if rate is invalid, see Rate.Validate.
+func RateLimit(store Store, rate Rate, opts ...Option) app.HandlerFunc {
+	l, err := NewRateLimiter(store, rate, opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+	keyFunc := l.opts.KeyFunc
+	return func(c context.Context, ctx *app.RequestContext) {
+		var key string
+		if keyFunc != nil {
+			key = keyFunc(c, ctx)
+		}
+		if err := l.Take(c, key); err != nil {
+			ctx.AbortWithError(consts.StatusTooManyRequests, err)
+			ctx.String(consts.StatusTooManyRequests, ctx.Errors.String())
+			return
+		}
+		ctx.Next(c)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/hertz-contrib/limiter"
+)
+
+// defaultStaleAfter is how long a summary is kept after its instance stopped publishing
+const defaultStaleAfter = time.Minute
+
+// ClusterStore implements limiter.ClusterStore by a redis hash of instance to summary,
+// summaries of instances which stopped publishing are removed when fetching.
+type ClusterStore struct {
+	client     goredis.Cmdable
+	key        string
+	staleAfter time.Duration
+}
+
+// ClusterOption is the option of a ClusterStore
+type ClusterOption func(s *ClusterStore)
+
+// WithStaleAfter defines how long a summary is kept after it was published, default is one minute,
+// it should be longer than 3 times the ClusterInterval.
+func WithStaleAfter(d time.Duration) ClusterOption {
+	return func(s *ClusterStore) {
+		s.staleAfter = d
+	}
+}
+
+// NewClusterStore returns a ClusterStore keeping summaries in the hash named key
+func NewClusterStore(client goredis.Cmdable, key string, opts ...ClusterOption) *ClusterStore {
+	s := &ClusterStore{
+		client:     client,
+		key:        key,
+		staleAfter: defaultStaleAfter,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Publish implements limiter.ClusterStore
+func (s *ClusterStore) Publish(ctx context.Context, summary limiter.WindowSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.key, summary.Instance, data).Err()
+}
+
+// Summaries implements limiter.ClusterStore, stale and malformed fields are deleted
+func (s *ClusterStore) Summaries(ctx context.Context) ([]limiter.WindowSummary, error) {
+	values, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(-s.staleAfter)
+	summaries := make([]limiter.WindowSummary, 0, len(values))
+	var stale []string
+	for field, value := range values {
+		var summary limiter.WindowSummary
+		if err := json.Unmarshal([]byte(value), &summary); err != nil || summary.Time.Before(deadline) {
+			stale = append(stale, field)
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	if len(stale) > 0 {
+		// a failure only delays the cleanup to the next fetch
+		_ = s.client.HDel(ctx, s.key, stale...).Err()
+	}
+	return summaries, nil
+}
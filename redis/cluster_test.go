@@ -0,0 +1,53 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hertz-contrib/limiter"
+)
+
+func TestClusterStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := NewClusterStore(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}), "bbr")
+	ctx := context.Background()
+	now := time.Now().UTC().Round(0)
+	assert.Nil(t, s.Publish(ctx, limiter.WindowSummary{Instance: "a", MaxPass: 1, MinRT: 1 * time.Millisecond, Time: now}))
+	assert.Nil(t, s.Publish(ctx, limiter.WindowSummary{Instance: "a", MaxPass: 2, MinRT: 2 * time.Millisecond, Time: now}))
+	assert.Nil(t, s.Publish(ctx, limiter.WindowSummary{Instance: "gone", MaxPass: 3, MinRT: 3 * time.Millisecond, Time: now.Add(-2 * time.Minute)}))
+	mr.HSet("bbr", "broken", "{")
+
+	summaries, err := s.Summaries(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []limiter.WindowSummary{{Instance: "a", MaxPass: 2, MinRT: 2 * time.Millisecond, Time: now}}, summaries)
+	// stale and malformed fields are deleted
+	fields, _ := mr.HKeys("bbr")
+	assert.Equal(t, []string{"a"}, fields)
+
+	s = NewClusterStore(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}), "bbr", WithStaleAfter(time.Nanosecond))
+	summaries, err = s.Summaries(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, summaries)
+	assert.False(t, mr.Exists("bbr"))
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package redis provides the redis Store of the rate limiters, so that the quota is shared
+// across instances. Every algorithm is an atomic lua script using the redis clock.
+package redis
+
+import (
+	"context"
+	"strconv"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/hertz-contrib/limiter"
+)
+
+const defaultPrefix = "hertz:limiter:"
+
+// luaNow reads the redis clock in microseconds and the arguments shared by all scripts
+const luaNow = `
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+`
+
+var tokenBucket = goredis.NewScript(luaNow + `
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1])
+if tokens == nil then
+	tokens = burst
+else
+	tokens = math.min(burst, tokens + (now - tonumber(state[2])) / period * limit)
+end
+local allowed = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'last', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / limit * period / 1000) + 1000)
+return allowed
+`)
+
+var slidingWindow = goredis.NewScript(luaNow + `
+local start = now - now % period
+local state = redis.call('HMGET', KEYS[1], 'start', 'prev', 'curr')
+local prev = tonumber(state[2]) or 0
+local curr = tonumber(state[3]) or 0
+if tonumber(state[1]) ~= start then
+	if start - (tonumber(state[1]) or 0) == period then
+		prev = curr
+	else
+		prev = 0
+	end
+	curr = 0
+end
+local allowed = 0
+if prev * (1 - (now - start) / period) + curr + n <= limit then
+	curr = curr + n
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'start', start, 'prev', prev, 'curr', curr)
+redis.call('PEXPIRE', KEYS[1], math.ceil(period * 2 / 1000))
+return allowed
+`)
+
+var gcra = goredis.NewScript(luaNow + `
+local interval = period / limit
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+if tat < now then
+	tat = now
+end
+local newTat = tat + n * interval
+if newTat - now > burst * interval then
+	return 0
+end
+redis.call('SET', KEYS[1], newTat, 'PX', math.ceil((newTat - now) / 1000) + 1)
+return 1
+`)
+
+var scripts = map[limiter.Algorithm]*goredis.Script{
+	limiter.TokenBucket:   tokenBucket,
+	limiter.SlidingWindow: slidingWindow,
+	limiter.GCRA:          gcra,
+}
+
+// Option customizes the Store
+type Option func(s *Store)
+
+// WithPrefix defines the prefix of redis keys, default is "hertz:limiter:"
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// Store implements limiter.Store by redis
+type Store struct {
+	client goredis.Scripter
+	prefix string
+}
+
+// NewStore returns a Store using client, which can be a client, cluster client or ring
+func NewStore(client goredis.Scripter, opts ...Option) *Store {
+	s := &Store{
+		client: client,
+		prefix: defaultPrefix,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Take implements limiter.Store
+func (s *Store) Take(ctx context.Context, key string, algorithm limiter.Algorithm, rate limiter.Rate, n int64) (bool, error) {
+	if err := rate.Validate(); err != nil {
+		return false, err
+	}
+	script, ok := scripts[algorithm]
+	if !ok {
+		script = tokenBucket
+	}
+	burst := rate.Burst
+	if burst <= 0 {
+		burst = rate.Limit
+	}
+	redisKey := s.prefix + strconv.Itoa(int(algorithm)) + ":" + key
+	allowed, err := script.Run(ctx, s.client, []string{redisKey},
+		rate.Limit, rate.Period.Microseconds(), burst, n).Int()
+	if err != nil {
+		return false, err
+	}
+	return allowed == 1, nil
+}
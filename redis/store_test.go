@@ -0,0 +1,95 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hertz-contrib/limiter"
+)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Unix(1000, 0))
+	return NewStore(goredis.NewClient(&goredis.Options{Addr: mr.Addr()})), mr
+}
+
+func take(t *testing.T, s *Store, algorithm limiter.Algorithm, rate limiter.Rate, times int) int {
+	var allowed int
+	for i := 0; i < times; i++ {
+		ok, err := s.Take(context.Background(), "key", algorithm, rate, 1)
+		assert.Nil(t, err)
+		if ok {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestTokenBucket(t *testing.T) {
+	s, mr := newTestStore(t)
+	rate := limiter.Rate{Limit: 10, Period: time.Second, Burst: 5}
+	assert.Equal(t, 5, take(t, s, limiter.TokenBucket, rate, 10))
+	mr.SetTime(time.Unix(1000, int64(200*time.Millisecond)))
+	assert.Equal(t, 2, take(t, s, limiter.TokenBucket, rate, 10))
+}
+
+func TestSlidingWindow(t *testing.T) {
+	s, mr := newTestStore(t)
+	rate := limiter.PerSecond(10)
+	assert.Equal(t, 10, take(t, s, limiter.SlidingWindow, rate, 20))
+	// 10 * (1 - 0.7) of the previous window is counted
+	mr.SetTime(time.Unix(1001, int64(700*time.Millisecond)))
+	assert.Equal(t, 7, take(t, s, limiter.SlidingWindow, rate, 20))
+	mr.SetTime(time.Unix(1003, 0))
+	assert.Equal(t, 10, take(t, s, limiter.SlidingWindow, rate, 20))
+}
+
+func TestGCRA(t *testing.T) {
+	s, mr := newTestStore(t)
+	rate := limiter.Rate{Limit: 10, Period: time.Second, Burst: 3}
+	assert.Equal(t, 3, take(t, s, limiter.GCRA, rate, 10))
+	mr.SetTime(time.Unix(1000, int64(100*time.Millisecond)))
+	assert.Equal(t, 1, take(t, s, limiter.GCRA, rate, 10))
+}
+
+func TestInvalidRate(t *testing.T) {
+	s, _ := newTestStore(t)
+	_, err := s.Take(context.Background(), "key", limiter.GCRA, limiter.Rate{Period: time.Second}, 1)
+	assert.ErrorIs(t, err, limiter.ErrInvalidRate)
+}
+
+func TestFallback(t *testing.T) {
+	s, mr := newTestStore(t)
+	mr.Close()
+	l, err := limiter.NewRateLimiter(s, limiter.PerSecond(1), limiter.WithFallback(limiter.FallbackLocal))
+	assert.Nil(t, err)
+	defer l.Close()
+	assert.Nil(t, l.Take(context.Background(), "key"))
+	assert.Equal(t, limiter.ErrLimitExceeded, l.Take(context.Background(), "key"))
+
+	l, _ = limiter.NewRateLimiter(s, limiter.PerSecond(1), limiter.WithFallback(limiter.FallbackDeny))
+	assert.Equal(t, limiter.ErrLimitExceeded, l.Take(context.Background(), "key"))
+	l, _ = limiter.NewRateLimiter(s, limiter.PerSecond(1), limiter.WithFallback(limiter.FallbackAllow))
+	assert.Nil(t, l.Take(context.Background(), "key"))
+}
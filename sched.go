@@ -0,0 +1,101 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"math"
+	"runtime/metrics"
+	"sync/atomic"
+)
+
+var (
+	// gSchedLatency is the p99 scheduling latency in nanoseconds during the last sampling interval
+	gSchedLatency int64
+	gGoroutines   int64
+)
+
+// schedSampler samples the scheduling latency since its previous sample, it's not safe for concurrent use
+type schedSampler struct {
+	samples    []metrics.Sample
+	prevCounts []uint64 // the histogram of the previous sample
+}
+
+func newSchedSampler() *schedSampler {
+	return &schedSampler{
+		samples: []metrics.Sample{
+			{Name: "/sched/latencies:seconds"},
+			{Name: "/sched/goroutines:goroutines"},
+		},
+	}
+}
+
+// schedQuantile returns the q-quantile in seconds of the histogram counts minus prev counts,
+// the upper bound of the bucket is used, or the lower bound if it's infinite.
+func schedQuantile(h *metrics.Float64Histogram, prev []uint64, q float64) float64 {
+	var total uint64
+	delta := make([]uint64, len(h.Counts))
+	for i, c := range h.Counts {
+		delta[i] = c
+		if i < len(prev) {
+			delta[i] -= prev[i]
+		}
+		total += delta[i]
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(float64(total) * q))
+	var seen uint64
+	for i, c := range delta {
+		seen += c
+		if seen < rank {
+			continue
+		}
+		if upper := h.Buckets[i+1]; !math.IsInf(upper, 1) {
+			return upper
+		}
+		return math.Max(h.Buckets[i], 0)
+	}
+	return 0
+}
+
+// sample returns the p99 scheduling latency in nanoseconds since the previous sample and the goroutine count,
+// -1 is returned for a metric not supported by the go runtime.
+func (s *schedSampler) sample() (latency, goroutines int64) {
+	latency, goroutines = -1, -1
+	metrics.Read(s.samples)
+	if s.samples[0].Value.Kind() == metrics.KindFloat64Histogram {
+		h := s.samples[0].Value.Float64Histogram()
+		latency = int64(schedQuantile(h, s.prevCounts, 0.99) * 1e9)
+		s.prevCounts = append(s.prevCounts[:0], h.Counts...)
+	}
+	if s.samples[1].Value.Kind() == metrics.KindUint64 {
+		goroutines = int64(s.samples[1].Value.Uint64())
+	}
+	return latency, goroutines
+}
+
+// proc stores the sample for the limiters, it's called on the ticker of systemProc
+func (s *schedSampler) proc() {
+	latency, goroutines := s.sample()
+	if latency >= 0 {
+		atomic.StoreInt64(&gSchedLatency, latency)
+	}
+	if goroutines >= 0 {
+		atomic.StoreInt64(&gGoroutines, goroutines)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"math"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/hertz-contrib/limiter/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedQuantile(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{90, 9, 1},
+		Buckets: []float64{0, 0.001, 0.01, math.Inf(1)},
+	}
+	assert.Equal(t, 0.001, schedQuantile(h, nil, 0.5))
+	assert.Equal(t, 0.01, schedQuantile(h, nil, 0.99))
+	// the lower bound of the infinite bucket
+	assert.Equal(t, 0.01, schedQuantile(h, nil, 1))
+	// only the delta since prev is counted
+	assert.Equal(t, 0.01, schedQuantile(h, []uint64{90, 0, 0}, 0.5))
+	assert.Equal(t, float64(0), schedQuantile(h, h.Counts, 0.99))
+}
+
+func TestSchedSampler(t *testing.T) {
+	s := newSchedSampler()
+	latency, goroutines := s.sample()
+	assert.Greater(t, goroutines, int64(0))
+	assert.GreaterOrEqual(t, latency, int64(0))
+	// the next sample only counts the latencies since this one
+	assert.NotEmpty(t, s.prevCounts)
+	latency, _ = s.sample()
+	assert.GreaterOrEqual(t, latency, int64(0))
+}
+
+func TestBBRShouldDropBySched(t *testing.T) {
+	var latency, goroutines int64
+	cpu := int64(1000)
+	bbr := newDropTestLimiter(utils.NewFakeClock(time.Now()), &cpu, WithoutCPU(),
+		WithSchedLatencyThreshold(10*time.Millisecond), WithGoroutineThreshold(10000))
+	bbr.schedLatency = func() int64 {
+		return latency
+	}
+	bbr.goroutines = func() int64 {
+		return goroutines
+	}
+
+	latency, goroutines = int64(time.Millisecond), 100
+	assert.Equal(t, false, bbr.shouldDrop())
+	latency = int64(20 * time.Millisecond)
+	assert.Equal(t, true, bbr.shouldDrop())
+	latency, goroutines = 0, 20000
+	assert.Equal(t, true, bbr.overloaded())
+}
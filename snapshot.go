@@ -0,0 +1,103 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
+
+// Snapshot is the window state of BBR, so a new instance can be seeded from a peer
+// or from the last snapshot written at shutdown.
+type Snapshot struct {
+	Pass utils.WindowSnapshot `json:"pass"`
+	RT   utils.WindowSnapshot `json:"rt"`
+	// RTUnit is the unit of RT, the RT is converted when restored by a limiter of another unit
+	RTUnit time.Duration `json:"rt_unit"`
+}
+
+// Snapshot exports the window state
+func (l *BBR) Snapshot() Snapshot {
+	return Snapshot{
+		Pass:   l.passStat.Snapshot(),
+		RT:     l.rtStat.Snapshot(),
+		RTUnit: l.config().opts.RTUnit,
+	}
+}
+
+// Restore merges the window state of s into the windows, buckets expired since s was taken are dropped.
+func (l *BBR) Restore(s Snapshot) {
+	l.passStat.Restore(s.Pass)
+	rt := s.RT
+	if unit := l.config().opts.RTUnit; s.RTUnit > 0 && s.RTUnit != unit {
+		scale := float64(s.RTUnit) / float64(unit)
+		rt.Buckets = make([]utils.BucketSnapshot, len(s.RT.Buckets))
+		for i, b := range s.RT.Buckets {
+			rt.Buckets[i] = utils.BucketSnapshot{Sum: b.Sum * scale, Count: b.Count}
+		}
+	}
+	l.rtStat.Restore(rt)
+	l.maxPASSCache.Store(&counterCache{})
+	l.minRtCache.Store(&counterCache{})
+	l.avgRtCache.Store(&counterCache{})
+	l.qpsCache.Store(&counterCache{})
+}
+
+// MarshalBinary encodes s compactly, the windows are prefixed by their length in uvarint,
+// followed by RTUnit in varint.
+func (s Snapshot) MarshalBinary() ([]byte, error) {
+	var buf []byte
+	var tmp [binary.MaxVarintLen64]byte
+	for _, w := range []utils.WindowSnapshot{s.Pass, s.RT} {
+		data, err := w.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(data)))]...)
+		buf = append(buf, data...)
+	}
+	buf = append(buf, tmp[:binary.PutVarint(tmp[:], int64(s.RTUnit))]...)
+	return buf, nil
+}
+
+// UnmarshalBinary decodes s encoded by MarshalBinary
+func (s *Snapshot) UnmarshalBinary(data []byte) error {
+	var windows [2]utils.WindowSnapshot
+	for i := range windows {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || size > uint64(len(data)-n) {
+			return utils.ErrInvalidSnapshot
+		}
+		if err := windows[i].UnmarshalBinary(data[n : n+int(size)]); err != nil {
+			return err
+		}
+		data = data[n+int(size):]
+	}
+	// snapshots without RTUnit are in millisecond
+	rtUnit := time.Millisecond
+	if len(data) > 0 {
+		unit, n := binary.Varint(data)
+		if n <= 0 {
+			return utils.ErrInvalidSnapshot
+		}
+		rtUnit = time.Duration(unit)
+	}
+	s.Pass, s.RT, s.RTUnit = windows[0], windows[1], rtUnit
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
+
+func TestBBRSnapshot(t *testing.T) {
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := NewLimiter(append(optsForTest, WithClock(clock))...)
+	for i := 1; i <= 3; i++ {
+		bbr.passStat.Add(float64(i * 100))
+		bbr.rtStat.Add(float64(i * 10))
+		clock.Advance(bucketDuration)
+	}
+	data, err := bbr.Snapshot().MarshalBinary()
+	assert.Nil(t, err)
+
+	// a new instance started blind
+	fresh := NewLimiter(append(optsForTest, WithClock(clock), WithShards(2))...)
+	assert.Equal(t, int64(1), fresh.maxPass())
+	var s Snapshot
+	assert.Nil(t, s.UnmarshalBinary(data))
+	fresh.Restore(s)
+	assert.Equal(t, int64(300), fresh.maxPass())
+	assert.Equal(t, int64(10), fresh.minRT())
+	assert.Equal(t, bbr.maxInFlight(), fresh.maxInFlight())
+
+	assert.Equal(t, utils.ErrInvalidSnapshot, s.UnmarshalBinary(data[:10]))
+}
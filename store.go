@@ -0,0 +1,184 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Algorithm is the rate limiting algorithm applied by a Store
+type Algorithm int
+
+const (
+	// TokenBucket refills Limit tokens per Period up to Burst tokens
+	TokenBucket Algorithm = iota
+	// SlidingWindow allows Limit events in any Period, approximated by weighting the previous fixed window
+	SlidingWindow
+	// GCRA is the generic cell rate algorithm, which spaces events evenly and allows Burst events at once
+	GCRA
+)
+
+// ErrInvalidRate is returned for a Rate with a non-positive Limit or Period, or a negative Burst
+var ErrInvalidRate = errors.New("invalid rate")
+
+// Rate defines Limit events per Period, with bursts of at most Burst events
+type Rate struct {
+	Limit  int64
+	Period time.Duration
+	// Burst defaults to Limit, it's not used by SlidingWindow
+	Burst int64
+}
+
+// PerSecond returns the Rate of limit events per second
+func PerSecond(limit int64) Rate {
+	return Rate{Limit: limit, Period: time.Second}
+}
+
+// PerMinute returns the Rate of limit events per minute
+func PerMinute(limit int64) Rate {
+	return Rate{Limit: limit, Period: time.Minute}
+}
+
+// Validate returns ErrInvalidRate if the rate can't be enforced
+func (r Rate) Validate() error {
+	if r.Limit <= 0 || r.Period <= 0 || r.Burst < 0 {
+		return fmt.Errorf("%w: %d per %s with burst %d", ErrInvalidRate, r.Limit, r.Period, r.Burst)
+	}
+	return nil
+}
+
+func (r Rate) burst() int64 {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
+
+// interval returns the time to emit one event
+func (r Rate) interval() time.Duration {
+	return r.Period / time.Duration(r.Limit)
+}
+
+// Store keeps the state of rate limiters, so that it can be shared across instances
+type Store interface {
+	// Take takes n events of key by algorithm at rate, it reports whether the events are allowed.
+	Take(ctx context.Context, key string, algorithm Algorithm, rate Rate, n int64) (bool, error)
+}
+
+// LocalStore is the in-memory Store, keys are bounded by WithMaxKeys and WithKeyTTL.
+// It's also the fallback when a shared store is unreachable.
+type LocalStore struct {
+	keys *KeyStore
+}
+
+// localState is the state of one key, fields are used according to the algorithm
+type localState struct {
+	lock        sync.Mutex
+	init        bool
+	tokens      float64   // TokenBucket
+	last        time.Time // TokenBucket
+	windowStart time.Time // SlidingWindow
+	prev, curr  int64     // SlidingWindow
+	tat         time.Time // GCRA theoretical arrival time
+}
+
+// NewLocalStore returns a LocalStore
+func NewLocalStore(opts ...Option) *LocalStore {
+	s := &LocalStore{
+		keys: newKeyStore(func() interface{} { return new(localState) }, opts...),
+	}
+	s.keys.Start()
+	return s
+}
+
+// Close removes the state of all keys and stops expiring them
+func (s *LocalStore) Close() {
+	s.keys.Close()
+}
+
+// Take implements Store
+func (s *LocalStore) Take(ctx context.Context, key string, algorithm Algorithm, rate Rate, n int64) (bool, error) {
+	if err := rate.Validate(); err != nil {
+		return false, err
+	}
+	state := s.keys.load(key).(*localState)
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	now := time.Now()
+	switch algorithm {
+	case SlidingWindow:
+		return state.slidingWindow(now, rate, n), nil
+	case GCRA:
+		return state.gcra(now, rate, n), nil
+	}
+	return state.tokenBucket(now, rate, n), nil
+}
+
+func (s *localState) tokenBucket(now time.Time, rate Rate, n int64) bool {
+	capacity := float64(rate.burst())
+	if !s.init {
+		s.init = true
+		s.tokens = capacity
+	} else {
+		refill := float64(now.Sub(s.last)) / float64(rate.Period) * float64(rate.Limit)
+		s.tokens = math.Min(capacity, s.tokens+refill)
+	}
+	s.last = now
+	if s.tokens < float64(n) {
+		return false
+	}
+	s.tokens -= float64(n)
+	return true
+}
+
+func (s *localState) slidingWindow(now time.Time, rate Rate, n int64) bool {
+	start := now.Truncate(rate.Period)
+	if !start.Equal(s.windowStart) {
+		if start.Sub(s.windowStart) == rate.Period {
+			s.prev = s.curr
+		} else {
+			s.prev = 0
+		}
+		s.curr = 0
+		s.windowStart = start
+	}
+	weight := 1 - float64(now.Sub(start))/float64(rate.Period)
+	if float64(s.prev)*weight+float64(s.curr+n) > float64(rate.Limit) {
+		return false
+	}
+	s.curr += n
+	return true
+}
+
+func (s *localState) gcra(now time.Time, rate Rate, n int64) bool {
+	interval := rate.interval()
+	tat := s.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(interval * time.Duration(n))
+	if newTat.Sub(now) > interval*time.Duration(rate.burst()) {
+		return false
+	}
+	s.tat = newTat
+	return true
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func takeLocal(t *testing.T, s Store, algorithm Algorithm, rate Rate, times int) int {
+	var allowed int
+	for i := 0; i < times; i++ {
+		ok, err := s.Take(context.Background(), "key", algorithm, rate, 1)
+		assert.Nil(t, err)
+		if ok {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestLocalStore(t *testing.T) {
+	s := NewLocalStore()
+	defer s.Close()
+	rate := Rate{Limit: 10, Period: time.Hour, Burst: 5}
+	assert.Equal(t, 5, takeLocal(t, s, TokenBucket, rate, 10))
+	assert.Equal(t, 3, takeLocal(t, s, GCRA, Rate{Limit: 10, Period: time.Hour, Burst: 3}, 10))
+
+	s = NewLocalStore()
+	defer s.Close()
+	assert.Equal(t, 10, takeLocal(t, s, SlidingWindow, PerMinute(10), 20))
+}
+
+func TestLocalStateSlidingWindow(t *testing.T) {
+	var state localState
+	start := time.Unix(1000, 0)
+	rate := PerSecond(10)
+	for i := 0; i < 20; i++ {
+		state.slidingWindow(start, rate, 1)
+	}
+	var allowed int
+	for i := 0; i < 20; i++ {
+		if state.slidingWindow(start.Add(1700*time.Millisecond), rate, 1) {
+			allowed++
+		}
+	}
+	// 10 * (1 - 0.7) of the previous window is counted
+	assert.Equal(t, 7, allowed)
+}
+
+type brokenStore struct{}
+
+func (brokenStore) Take(ctx context.Context, key string, algorithm Algorithm, rate Rate, n int64) (bool, error) {
+	return false, errors.New("unreachable")
+}
+
+// flakyStore is unreachable while broken is set
+type flakyStore struct {
+	broken bool
+}
+
+func (s *flakyStore) Take(ctx context.Context, key string, algorithm Algorithm, rate Rate, n int64) (bool, error) {
+	if s.broken {
+		return false, errors.New("unreachable")
+	}
+	return true, nil
+}
+
+func TestRateLimiterFallbackLog(t *testing.T) {
+	var buf bytes.Buffer
+	hlog.SetOutput(&buf)
+	defer hlog.SetOutput(os.Stderr)
+
+	store := &flakyStore{broken: true}
+	l, _ := NewRateLimiter(store, PerSecond(100), WithFallback(FallbackAllow))
+	defer l.Close()
+	for i := 0; i < 10; i++ {
+		_, _ = l.Allow()
+	}
+	// logged once when the store becomes unreachable
+	assert.Equal(t, 1, strings.Count(buf.String(), "store unreachable, fallback=allow"))
+	store.broken = false
+	for i := 0; i < 10; i++ {
+		_, _ = l.Allow()
+	}
+	assert.Equal(t, 1, strings.Count(buf.String(), "store recovered"))
+	store.broken = true
+	_, _ = l.Allow()
+	assert.Equal(t, 2, strings.Count(buf.String(), "store unreachable"))
+
+	assert.Equal(t, "deny", FallbackDeny.String())
+}
+
+func TestRateLimiterFallback(t *testing.T) {
+	l, err := NewRateLimiter(brokenStore{}, PerMinute(1))
+	assert.Nil(t, err)
+	defer l.Close()
+	_, err = l.Allow()
+	assert.Nil(t, err)
+	_, err = l.Allow()
+	assert.Equal(t, ErrLimitExceeded, err)
+
+	l, _ = NewRateLimiter(brokenStore{}, PerMinute(1), WithFallback(FallbackDeny))
+	_, err = l.Allow()
+	assert.Equal(t, ErrLimitExceeded, err)
+	l.Close()
+}
+
+func TestRateValidate(t *testing.T) {
+	for _, rate := range []Rate{{}, {Limit: 1}, {Period: time.Second}, {Limit: -1, Period: time.Second}, {Limit: 1, Period: time.Second, Burst: -1}} {
+		assert.ErrorIs(t, rate.Validate(), ErrInvalidRate, rate)
+		_, err := NewRateLimiter(NewLocalStore(), rate)
+		assert.ErrorIs(t, err, ErrInvalidRate)
+		assert.Panics(t, func() { RateLimit(NewLocalStore(), rate) })
+		for _, algorithm := range []Algorithm{TokenBucket, SlidingWindow, GCRA} {
+			_, err = NewLocalStore().Take(context.Background(), "key", algorithm, rate, 1)
+			assert.ErrorIs(t, err, ErrInvalidRate)
+		}
+	}
+	assert.Nil(t, PerSecond(1).Validate())
+}
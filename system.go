@@ -0,0 +1,104 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/c9s/goprocinfo/linux"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
+
+// gLoad is the bits of load1 read from /proc/loadavg
+var gLoad uint64
+
+// loadProc samples load1, it's called on the ticker of systemProc
+func loadProc() {
+	loadAvg, err := linux.ReadLoadAvg("/proc/loadavg")
+	if err != nil {
+		return
+	}
+	atomic.StoreUint64(&gLoad, math.Float64bits(loadAvg.Last1Min))
+}
+
+func getLoad() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&gLoad))
+}
+
+// systemOverloaded checks the sentinel system rules: load1, average RT, entry QPS and concurrency,
+// each of them is disabled when its threshold is 0.
+func (l *BBR) systemOverloaded(opts options) bool {
+	if opts.LoadThreshold > 0 && l.load() >= opts.LoadThreshold {
+		return true
+	}
+	if opts.MaxConcurrency > 0 && atomic.LoadInt64(&l.inFlight) >= opts.MaxConcurrency {
+		return true
+	}
+	if opts.QPSThreshold > 0 && l.qps() >= opts.QPSThreshold {
+		return true
+	}
+	// compared in durations, so that a threshold below one RTUnit isn't truncated to 0
+	return opts.AvgRTThreshold > 0 && time.Duration(l.avgRT())*opts.RTUnit >= opts.AvgRTThreshold
+}
+
+// qps returns the average passed requests per second in the window
+func (l *BBR) qps() int64 {
+	return l.cachedStat(&l.qpsCache, func() int64 {
+		var sum float64
+		var buckets int64
+		l.passStat.Reduce(func(b *utils.Bucket) {
+			sum += b.Sum
+			buckets++
+		})
+		if buckets == 0 {
+			return 0
+		}
+		return int64(sum * float64(l.config().bucketPerSecond) / float64(buckets))
+	})
+}
+
+// avgRT returns the average response time in RTUnit in the window
+func (l *BBR) avgRT() int64 {
+	return l.cachedStat(&l.avgRtCache, func() int64 {
+		var sum float64
+		var count int64
+		l.rtStat.Reduce(func(b *utils.Bucket) {
+			sum += b.Sum
+			count += b.Count
+		})
+		if count == 0 {
+			return 0
+		}
+		return int64(math.Ceil(sum / float64(count)))
+	})
+}
+
+// cachedStat returns the cached value computed in the same bucket, or computes it by fn
+func (l *BBR) cachedStat(cache *atomic.Value, fn func() int64) int64 {
+	if c, ok := cache.Load().(*counterCache); ok && l.timespan(c.time) < 1 {
+		return c.val
+	}
+	val := fn()
+	cache.Store(&counterCache{
+		val:  val,
+		time: l.clock.Now(),
+	})
+	return val
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
+
+func newSystemLimiter(opts ...Option) *BBR {
+	bucketDuration := windowSizeTest / time.Duration(bucketNumTest)
+	bbr := NewLimiter(append(append(optsForTest, WithoutCPU()), opts...)...)
+	bbr.load = func() float64 {
+		return 0
+	}
+	bbr.passStat = utils.NewRollingWindow(bucketNumTest, bucketDuration)
+	bbr.rtStat = utils.NewRollingWindow(bucketNumTest, bucketDuration)
+	return bbr
+}
+
+func TestBBRSystemLoad(t *testing.T) {
+	load := 2.0
+	bbr := newSystemLimiter(WithLoadThreshold(4))
+	bbr.load = func() float64 {
+		return load
+	}
+	assert.Equal(t, false, bbr.overloaded())
+	load = 4.5
+	assert.Equal(t, true, bbr.overloaded())
+}
+
+func TestBBRSystemConcurrency(t *testing.T) {
+	bbr := newSystemLimiter(WithMaxConcurrency(100))
+	bbr.inFlight = 99
+	assert.Equal(t, false, bbr.overloaded())
+	bbr.inFlight = 100
+	assert.Equal(t, true, bbr.overloaded())
+}
+
+func TestBBRSystemQPS(t *testing.T) {
+	bbr := newSystemLimiter(WithQPSThreshold(1000))
+	// 500 in a window of 1s
+	bbr.passStat.Add(500)
+	assert.Equal(t, int64(500), bbr.qps())
+	assert.Equal(t, false, bbr.overloaded())
+
+	bbr.qpsCache.Store(&counterCache{})
+	bbr.passStat.Add(600)
+	assert.Equal(t, int64(1100), bbr.qps())
+	assert.Equal(t, true, bbr.overloaded())
+}
+
+func TestBBRSystemAvgRT(t *testing.T) {
+	bbr := newSystemLimiter(WithAvgRTThreshold(100 * time.Millisecond))
+	bbr.rtStat.Add(40)
+	bbr.rtStat.Add(60)
+	assert.Equal(t, int64(50), bbr.avgRT())
+	assert.Equal(t, false, bbr.overloaded())
+
+	// cached within a bucket
+	bbr.rtStat.Add(500)
+	assert.Equal(t, int64(50), bbr.avgRT())
+	bbr.avgRtCache.Store(&counterCache{})
+	assert.Equal(t, int64(200), bbr.avgRT())
+	assert.Equal(t, true, bbr.overloaded())
+}
+
+func TestBBRSystemAvgRTBelowUnit(t *testing.T) {
+	bbr := newSystemLimiter(WithAvgRTThreshold(500*time.Microsecond), WithRTUnit(time.Millisecond))
+	// no requests yet
+	assert.Equal(t, false, bbr.overloaded())
+	bbr.rtStat.Add(1)
+	bbr.avgRtCache.Store(&counterCache{})
+	assert.Equal(t, true, bbr.overloaded())
+}
+
+func TestBBRShouldDropBySystemRules(t *testing.T) {
+	bbr := newSystemLimiter(WithMaxConcurrency(50))
+	bbr.passStat.Add(10)
+	bbr.rtStat.Add(10)
+	// below the concurrency threshold, never drop
+	bbr.inFlight = 40
+	assert.Equal(t, false, bbr.shouldDrop())
+	// exceeds the threshold and the estimated capacity
+	bbr.inFlight = 60
+	assert.Equal(t, true, bbr.shouldDrop())
+}
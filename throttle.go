@@ -0,0 +1,118 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
+
+// Throttler implements the client side adaptive throttling from Google SRE.
+// Requests are rejected locally with probability max(0, (requests - K*accepts) / (requests + 1)).
+// https://sre.google/sre-book/handling-overload/#eq2101
+type Throttler struct {
+	stat   *utils.RollingWindow // Sum is accepts, Count is requests
+	k      float64
+	random func() float64
+}
+
+// NewThrottler returns a Throttler, the statistics are kept over WithWindow and WithBucket.
+func NewThrottler(opts ...Option) *Throttler {
+	opt := NewOption(opts...)
+	bucketDuration := opt.Window / time.Duration(opt.Bucket)
+	return &Throttler{
+		stat:   utils.NewRollingWindow(opt.Bucket, bucketDuration),
+		k:      opt.K,
+		random: rand.Float64,
+	}
+}
+
+// summary returns accepts and requests in the window
+func (t *Throttler) summary() (accepts, requests int64) {
+	t.stat.Reduce(func(b *utils.Bucket) {
+		accepts += int64(b.Sum)
+		requests += b.Count
+	})
+	return
+}
+
+// probability returns the client rejection probability
+func (t *Throttler) probability() float64 {
+	accepts, requests := t.summary()
+	return math.Max(0, (float64(requests)-t.k*float64(accepts))/float64(requests+1))
+}
+
+// Allow rejects the request locally with the client rejection probability,
+// rejected requests are counted as requests without accept.
+func (t *Throttler) Allow() error {
+	if p := t.probability(); p > 0 && t.random() < p {
+		t.MarkFailed()
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+// MarkSuccess records a request accepted by backend
+func (t *Throttler) MarkSuccess() {
+	t.stat.Add(1)
+}
+
+// MarkFailed records a request rejected by backend
+func (t *Throttler) MarkFailed() {
+	t.stat.Add(0)
+}
+
+// ClientThrottle is a client middleware throttling outbound requests,
+// transport errors, 429 and 5xx responses are treated as rejected by backend.
+func ClientThrottle(opts ...Option) client.Middleware {
+	return throttleMiddleware(NewThrottler(opts...))
+}
+
+func throttleMiddleware(t *Throttler) client.Middleware {
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			return roundTripThrottler(t, next, ctx, req, resp)
+		}
+	}
+}
+
+// roundTripThrottler calls next through t
+func roundTripThrottler(t *Throttler, next client.Endpoint, ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+	if err := t.Allow(); err != nil {
+		return err
+	}
+	err := next(ctx, req, resp)
+	if err != nil || isRejectedStatus(resp.StatusCode()) {
+		t.MarkFailed()
+	} else {
+		t.MarkSuccess()
+	}
+	return err
+}
+
+// isRejectedStatus reports whether the status code means the backend rejected the request
+func isRejectedStatus(code int) bool {
+	return code == consts.StatusTooManyRequests || code >= consts.StatusInternalServerError
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottlerProbability(t *testing.T) {
+	throttler := NewThrottler(optsForTest...)
+	assert.Equal(t, float64(0), throttler.probability())
+
+	// requests within K times of accepts are never rejected
+	for i := 0; i < 10; i++ {
+		throttler.MarkSuccess()
+		throttler.MarkFailed()
+	}
+	assert.Equal(t, float64(0), throttler.probability())
+
+	for i := 0; i < 20; i++ {
+		throttler.MarkFailed()
+	}
+	// (40 - 2*10) / 41
+	assert.InDelta(t, 20.0/41, throttler.probability(), 1e-9)
+}
+
+func TestThrottlerAllow(t *testing.T) {
+	throttler := NewThrottler(optsForTest...)
+	for i := 0; i < 10; i++ {
+		throttler.MarkFailed()
+	}
+	throttler.random = func() float64 { return 0.99 }
+	assert.Nil(t, throttler.Allow())
+	throttler.random = func() float64 { return 0 }
+	assert.Equal(t, ErrLimitExceeded, throttler.Allow())
+	// local rejection is counted as a request
+	accepts, requests := throttler.summary()
+	assert.Equal(t, int64(0), accepts)
+	assert.Equal(t, int64(11), requests)
+}
+
+func TestClientThrottle(t *testing.T) {
+	throttler := NewThrottler(optsForTest...)
+	throttler.random = func() float64 { return 0 }
+	var status int
+	var callErr error
+	endpoint := throttleMiddleware(throttler)(func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+		resp.SetStatusCode(status)
+		return callErr
+	})
+	call := func() error {
+		return endpoint(context.Background(), &protocol.Request{}, &protocol.Response{})
+	}
+
+	status = consts.StatusOK
+	assert.Nil(t, call())
+	status = consts.StatusServiceUnavailable
+	assert.Nil(t, call())
+	callErr = errors.New("dial failed")
+	assert.Equal(t, callErr, call())
+	accepts, requests := throttler.summary()
+	assert.Equal(t, int64(1), accepts)
+	assert.Equal(t, int64(3), requests)
+
+	// (3 - 2*1) / 4 > 0
+	assert.Equal(t, ErrLimitExceeded, call())
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tokenserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"github.com/hertz-contrib/limiter"
+)
+
+// Transport sends token requests to the token server,
+// it must return once the deadline of ctx is exceeded.
+type Transport interface {
+	Acquire(ctx context.Context, req TokenRequest) (TokenResult, error)
+}
+
+// httpTransport requests tokens over HTTP
+type httpTransport struct {
+	client *client.Client
+	url    string
+}
+
+// NewHTTPTransport returns a Transport posting token requests to url served by Server.Handler.
+// Requests are bounded by the deadline of the context, set the ReadTimeout of c as well,
+// as the hertz client keeps a timed out request running until it's read.
+func NewHTTPTransport(c *client.Client, url string) Transport {
+	return &httpTransport{client: c, url: url}
+}
+
+func (t *httpTransport) Acquire(ctx context.Context, tokenReq TokenRequest) (TokenResult, error) {
+	var result TokenResult
+	body, err := json.Marshal(tokenReq)
+	if err != nil {
+		return result, err
+	}
+	req, resp := protocol.AcquireRequest(), protocol.AcquireResponse()
+	defer func() {
+		protocol.ReleaseRequest(req)
+		protocol.ReleaseResponse(resp)
+	}()
+	req.SetRequestURI(t.url)
+	req.Header.SetMethod(consts.MethodPost)
+	req.Header.SetContentTypeBytes([]byte("application/json"))
+	req.SetBody(body)
+	if deadline, ok := ctx.Deadline(); ok {
+		err = t.client.DoDeadline(ctx, req, resp, deadline)
+	} else {
+		err = t.client.Do(ctx, req, resp)
+	}
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode() != consts.StatusOK {
+		return result, fmt.Errorf("token server responded %d: %s", resp.StatusCode(), resp.Body())
+	}
+	err = json.Unmarshal(resp.Body(), &result)
+	return result, err
+}
+
+// Client requests tokens from the token server, it implements limiter.Store,
+// the key is used as the resource, the rate and algorithm are owned by the server.
+type Client struct {
+	transport Transport
+	timeout   time.Duration
+}
+
+// NewClient returns a Client, requests not responded in timeout fail,
+// so that limiter.RateLimiter falls back by limiter.WithFallback.
+func NewClient(transport Transport, timeout time.Duration) *Client {
+	return &Client{
+		transport: transport,
+		timeout:   timeout,
+	}
+}
+
+// Take implements limiter.Store
+func (c *Client) Take(ctx context.Context, key string, algorithm limiter.Algorithm, rate limiter.Rate, n int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	result, err := c.transport.Acquire(ctx, TokenRequest{Resource: key, Count: n})
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return false, err
+	}
+	return result.Status != StatusBlocked, nil
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tokenserver provides a token server owning the global quotas per resource,
+// and a client requesting tokens from it, like the cluster flow control of sentinel.
+// The client implements limiter.Store, so that limiter.RateLimit can consult the token server
+// and fall back to local limiting when the token server is unreachable.
+package tokenserver
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"github.com/hertz-contrib/limiter"
+)
+
+// Status is the result status of a token request
+type Status int
+
+const (
+	// StatusOK means the tokens are acquired
+	StatusOK Status = iota
+	// StatusBlocked means the quota of the resource is exhausted
+	StatusBlocked
+	// StatusNoRule means the resource has no quota, the request is not limited
+	StatusNoRule
+)
+
+// TokenRequest requests Count tokens of Resource
+type TokenRequest struct {
+	Resource string `json:"resource"`
+	Count    int64  `json:"count"`
+}
+
+// TokenResult is the result of a TokenRequest
+type TokenResult struct {
+	Status Status `json:"status"`
+}
+
+// Rule defines the global quota of a resource
+type Rule struct {
+	Resource  string
+	Rate      limiter.Rate
+	Algorithm limiter.Algorithm
+}
+
+// Server owns the global quotas, it can be served over HTTP by Handler or used in-process as a Transport
+type Server struct {
+	lock  sync.RWMutex
+	rules map[string]Rule
+	store *limiter.LocalStore
+}
+
+// NewServer returns a Server with rules, the quota state is kept in a limiter.LocalStore
+func NewServer(rules ...Rule) *Server {
+	s := &Server{store: limiter.NewLocalStore()}
+	s.SetRules(rules...)
+	return s
+}
+
+// Close releases the quota state of all resources
+func (s *Server) Close() {
+	s.store.Close()
+}
+
+// SetRules replaces all rules
+func (s *Server) SetRules(rules ...Rule) {
+	m := make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		m[rule.Resource] = rule
+	}
+	s.lock.Lock()
+	s.rules = m
+	s.lock.Unlock()
+}
+
+// Acquire implements Transport
+func (s *Server) Acquire(ctx context.Context, req TokenRequest) (TokenResult, error) {
+	s.lock.RLock()
+	rule, ok := s.rules[req.Resource]
+	s.lock.RUnlock()
+	if !ok {
+		return TokenResult{Status: StatusNoRule}, nil
+	}
+	if req.Count < 1 {
+		req.Count = 1
+	}
+	allowed, err := s.store.Take(ctx, req.Resource, rule.Algorithm, rule.Rate, req.Count)
+	if err != nil {
+		return TokenResult{}, err
+	}
+	if !allowed {
+		return TokenResult{Status: StatusBlocked}, nil
+	}
+	return TokenResult{Status: StatusOK}, nil
+}
+
+// Handler serves token requests in JSON, e.g. h.POST("/token", s.Handler())
+func (s *Server) Handler() app.HandlerFunc {
+	return func(c context.Context, ctx *app.RequestContext) {
+		var req TokenRequest
+		if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+			ctx.AbortWithError(consts.StatusBadRequest, err)
+			ctx.String(consts.StatusBadRequest, ctx.Errors.String())
+			return
+		}
+		result, err := s.Acquire(c, req)
+		if err != nil {
+			ctx.AbortWithError(consts.StatusInternalServerError, err)
+			ctx.String(consts.StatusInternalServerError, ctx.Errors.String())
+			return
+		}
+		ctx.JSON(consts.StatusOK, result)
+	}
+}
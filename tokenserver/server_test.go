@@ -0,0 +1,139 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tokenserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hertz-contrib/limiter"
+)
+
+func TestServerAcquire(t *testing.T) {
+	s := NewServer(Rule{Resource: "api", Rate: limiter.PerMinute(2)})
+	ctx := context.Background()
+	for _, status := range []Status{StatusOK, StatusOK, StatusBlocked} {
+		result, err := s.Acquire(ctx, TokenRequest{Resource: "api", Count: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, status, result.Status)
+	}
+	result, _ := s.Acquire(ctx, TokenRequest{Resource: "other", Count: 1})
+	assert.Equal(t, StatusNoRule, result.Status)
+}
+
+func TestServerHandler(t *testing.T) {
+	s := NewServer(Rule{Resource: "api", Rate: limiter.PerMinute(1)})
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/token", s.Handler())
+
+	body := `{"resource":"api","count":1}`
+	for _, status := range []Status{StatusOK, StatusBlocked} {
+		resp := ut.PerformRequest(engine, consts.MethodPost, "/token", &ut.Body{Body: bytes.NewBufferString(body), Len: len(body)}).Result()
+		assert.Equal(t, consts.StatusOK, resp.StatusCode())
+		var result TokenResult
+		assert.Nil(t, json.Unmarshal(resp.Body(), &result))
+		assert.Equal(t, status, result.Status)
+	}
+	resp := ut.PerformRequest(engine, consts.MethodPost, "/token", &ut.Body{Body: bytes.NewBufferString("{"), Len: 1}).Result()
+	assert.Equal(t, consts.StatusBadRequest, resp.StatusCode())
+}
+
+type slowTransport struct{}
+
+func (slowTransport) Acquire(ctx context.Context, req TokenRequest) (TokenResult, error) {
+	select {
+	case <-time.After(100 * time.Millisecond):
+		return TokenResult{}, errors.New("unreachable")
+	case <-ctx.Done():
+		return TokenResult{}, errors.New("canceled")
+	}
+}
+
+func TestClient(t *testing.T) {
+	// the server is used in-process as the transport
+	c := NewClient(NewServer(Rule{Resource: "api", Rate: limiter.PerMinute(1)}), time.Second)
+	l, err := limiter.NewRateLimiter(c, limiter.PerMinute(100))
+	assert.Nil(t, err)
+	ctx := context.Background()
+	assert.Nil(t, l.Take(ctx, "api"))
+	assert.Equal(t, limiter.ErrLimitExceeded, l.Take(ctx, "api"))
+	assert.Nil(t, l.Take(ctx, "other"))
+
+	// fall back to local limiting on timeout
+	c = NewClient(slowTransport{}, 10*time.Millisecond)
+	_, err = c.Take(ctx, "api", limiter.TokenBucket, limiter.PerMinute(1), 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	l, _ = limiter.NewRateLimiter(c, limiter.PerMinute(1))
+	assert.Nil(t, l.Take(ctx, "api"))
+	assert.Equal(t, limiter.ErrLimitExceeded, l.Take(ctx, "api"))
+}
+
+func TestHTTPTransport(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s := NewServer(Rule{Resource: "api", Rate: limiter.PerMinute(1)})
+	defer s.Close()
+	h := server.New(server.WithHostPorts(addr))
+	h.POST("/token", s.Handler())
+	h.POST("/slow", func(c context.Context, ctx *app.RequestContext) {
+		time.Sleep(200 * time.Millisecond)
+		ctx.JSON(consts.StatusOK, TokenResult{})
+	})
+	go h.Run()
+	defer h.Close()
+	for i := 0; i < 100 && !h.IsRunning(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	hc, err := client.NewClient(client.WithClientReadTimeout(time.Second))
+	assert.Nil(t, err)
+	ctx := context.Background()
+	c := NewClient(NewHTTPTransport(hc, "http://"+addr+"/token"), time.Second)
+	for _, allowed := range []bool{true, false} {
+		ok, err := c.Take(ctx, "api", limiter.TokenBucket, limiter.PerMinute(100), 1)
+		assert.Nil(t, err)
+		assert.Equal(t, allowed, ok)
+	}
+
+	// the deadline is passed to the hertz client
+	c = NewClient(NewHTTPTransport(hc, "http://"+addr+"/slow"), 20*time.Millisecond)
+	start := time.Now()
+	_, err = c.Take(ctx, "api", limiter.TokenBucket, limiter.PerMinute(100), 1)
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+
+	c = NewClient(NewHTTPTransport(hc, "http://"+addr+"/missing"), time.Second)
+	_, err = c.Take(ctx, "api", limiter.TokenBucket, limiter.PerMinute(100), 1)
+	assert.Contains(t, err.Error(), "token server responded 404")
+}
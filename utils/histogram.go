@@ -0,0 +1,171 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import "math"
+
+const (
+	// histSubBuckets linear sub buckets for each power of two, so the relative error is under 1/8
+	histSubBuckets = 8
+	// histMaxExp values larger than 2^histMaxExp fall into the last bucket
+	histMaxExp = 40
+	histSize   = 1 + (histMaxExp+1)*histSubBuckets
+)
+
+// Histogram is a mergeable log-linear histogram of non-negative values.
+// Values under 1 share the first bucket, and each power of two above is split into 8 linear buckets.
+// The counts (about 2.6KB) are allocated by the first value, and kept by Reset.
+type Histogram struct {
+	counts   []uint64
+	count    int64
+	min, max float64
+}
+
+// HistogramBucket is a non-empty bucket of the distribution, holding values up to UpperBound
+type HistogramBucket struct {
+	UpperBound float64
+	Count      int64
+}
+
+// NewHistogram returns an empty Histogram
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// histIndex returns the bucket index of v
+func histIndex(v float64) int {
+	if !(v >= 1) {
+		return 0
+	}
+	// v = frac * 2^exp, frac in [0.5, 1)
+	frac, exp := math.Frexp(v)
+	e := exp - 1
+	if e > histMaxExp {
+		return histSize - 1
+	}
+	return 1 + e*histSubBuckets + int((frac*2-1)*histSubBuckets)
+}
+
+// histLowerBound returns the inclusive lower bound of bucket i
+func histLowerBound(i int) float64 {
+	if i == 0 {
+		return 0
+	}
+	return histUpperBound(i - 1)
+}
+
+// histUpperBound returns the exclusive upper bound of bucket i
+func histUpperBound(i int) float64 {
+	if i == 0 {
+		return 1
+	}
+	e, sub := (i-1)/histSubBuckets, (i-1)%histSubBuckets
+	return math.Ldexp(1+float64(sub+1)/histSubBuckets, e)
+}
+
+// Add records v
+func (h *Histogram) Add(v float64) {
+	if v < 0 {
+		v = 0
+	}
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	if h.count == 0 || v > h.max {
+		h.max = v
+	}
+	if h.counts == nil {
+		h.counts = make([]uint64, histSize)
+	}
+	h.counts[histIndex(v)]++
+	h.count++
+}
+
+// Count returns the number of recorded values
+func (h *Histogram) Count() int64 {
+	return h.count
+}
+
+// Merge adds all values recorded by o into h
+func (h *Histogram) Merge(o *Histogram) {
+	if o == nil || o.count == 0 {
+		return
+	}
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if h.count == 0 || o.max > h.max {
+		h.max = o.max
+	}
+	if h.counts == nil {
+		h.counts = make([]uint64, histSize)
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	h.count += o.count
+}
+
+// Reset clears all values
+func (h *Histogram) Reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.count, h.min, h.max = 0, 0, 0
+}
+
+// Quantile returns the q-quantile, e.g. 0.99 for p99, bounded by the min and max recorded, or the max for q >= 1.
+// The rank is interpolated linearly within its bucket, which halves the expected error of the bucket width (1/8),
+// while buckets narrower than 1 return their lower bound, so small integers are exact.
+// It returns 0 if the histogram is empty.
+func (h *Histogram) Quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	if q >= 1 {
+		return h.max
+	}
+	rank := uint64(math.Ceil(float64(h.count) * q))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen < rank {
+			continue
+		}
+		v, upper := histLowerBound(i), histUpperBound(i)
+		if upper-v > 1 {
+			// the rank-th of c values spread evenly over the bucket
+			v += (upper - v) * (float64(rank-(seen-c)) - 0.5) / float64(c)
+		}
+		return math.Max(math.Min(v, h.max), h.min)
+	}
+	return h.max
+}
+
+// Distribution returns the non-empty buckets in ascending order, e.g. to export a latency distribution
+func (h *Histogram) Distribution() []HistogramBucket {
+	var buckets []HistogramBucket
+	for i, c := range h.counts {
+		if c > 0 {
+			buckets = append(buckets, HistogramBucket{UpperBound: histUpperBound(i), Count: int64(c)})
+		}
+	}
+	return buckets
+}
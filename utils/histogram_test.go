@@ -0,0 +1,99 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramIndex(t *testing.T) {
+	assert.Equal(t, 0, histIndex(0))
+	assert.Equal(t, 0, histIndex(0.5))
+	assert.Equal(t, 1, histIndex(1))
+	for _, v := range []float64{1, 3, 10, 100, 1234.5, 1e6} {
+		i := histIndex(v)
+		assert.Less(t, v, histUpperBound(i))
+		assert.GreaterOrEqual(t, v, histUpperBound(i-1))
+		// relative error under 1/8
+		assert.Less(t, (histUpperBound(i)-v)/v, 0.125+1e-9)
+	}
+	assert.Equal(t, histSize-1, histIndex(1e300))
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := NewHistogram()
+	assert.Equal(t, float64(0), h.Quantile(0.99))
+	for i := 1; i <= 100; i++ {
+		h.Add(float64(i))
+	}
+	assert.Equal(t, int64(100), h.Count())
+	assert.InDelta(t, 50, h.Quantile(0.5), 50*0.125)
+	assert.InDelta(t, 99, h.Quantile(0.99), 99*0.125)
+	// bounded by min and max
+	assert.Equal(t, float64(1), h.Quantile(0))
+	assert.Equal(t, float64(100), h.Quantile(1))
+
+	h.Reset()
+	h.Add(10)
+	assert.Equal(t, float64(10), h.Quantile(0.5))
+}
+
+func TestHistogramInterpolate(t *testing.T) {
+	h := NewHistogram()
+	// [1024, 1152) holds all values
+	for i := 0; i < 128; i++ {
+		h.Add(float64(1024 + i))
+	}
+	assert.Equal(t, 1, len(h.Distribution()))
+	// the lower bound would be 1024
+	assert.InDelta(t, 1087.5, h.Quantile(0.5), 1)
+	assert.InDelta(t, 1150.5, h.Quantile(0.99), 1)
+}
+
+func TestHistogramLazy(t *testing.T) {
+	h := NewHistogram()
+	h.Merge(NewHistogram())
+	assert.Nil(t, h.counts)
+	h.Add(1)
+	assert.Len(t, h.counts, histSize)
+	// kept for the next values
+	h.Reset()
+	assert.Len(t, h.counts, histSize)
+	assert.Equal(t, int64(0), h.Count())
+	assert.Equal(t, float64(0), h.Quantile(0.5))
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	a.Add(1)
+	a.Add(2)
+	b.Add(100)
+	b.Add(200)
+	a.Merge(b)
+	a.Merge(nil)
+	assert.Equal(t, int64(4), a.Count())
+	assert.Equal(t, float64(200), a.Quantile(1))
+	assert.Equal(t, float64(1), a.Quantile(0.25))
+	var total int64
+	for _, bucket := range a.Distribution() {
+		total += bucket.Count
+	}
+	assert.Equal(t, int64(4), total)
+	assert.Len(t, a.Distribution(), 4)
+}
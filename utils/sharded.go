@@ -0,0 +1,170 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedRollingWindow spreads Add over shards of RollingWindow to reduce the lock contention on hot paths,
+// while Reduce merges the shards with the same semantics as a single RollingWindow.
+// It trades a slower Reduce for a faster parallel Add, which suits limiters caching the Reduce result.
+type ShardedRollingWindow struct {
+	shards []*RollingWindow
+	// handles caches a shard index per P, so goroutines on the same P keep adding to the same shard
+	handles sync.Pool
+	next    uint32
+}
+
+type shardHandle struct {
+	i int
+}
+
+// NewShardedRollingWindow returns a ShardedRollingWindow of shards RollingWindow, each with size buckets and time interval.
+func NewShardedRollingWindow(shards, size int, interval time.Duration, opts ...RollingWindowOption) *ShardedRollingWindow {
+	if shards < 1 {
+		panic("shards must be greater than 0")
+	}
+	w := &ShardedRollingWindow{shards: make([]*RollingWindow, shards)}
+	for i := range w.shards {
+		w.shards[i] = NewRollingWindow(size, interval, opts...)
+	}
+	// share the time grid, so buckets of shards are aligned
+	for _, shard := range w.shards {
+		shard.lastTime = w.shards[0].lastTime
+	}
+	// only a P without a cached handle takes the next index, Add does not share a counter
+	w.handles.New = func() interface{} {
+		return &shardHandle{i: int(atomic.AddUint32(&w.next, 1) % uint32(len(w.shards)))}
+	}
+	return w
+}
+
+// Add adds value to current bucket of the shard of the current P.
+func (w *ShardedRollingWindow) Add(v float64) {
+	h := w.handles.Get().(*shardHandle)
+	w.shards[h.i].Add(v)
+	w.handles.Put(h)
+}
+
+// Reduce runs fn on all buckets merged from the shards, ignore current bucket if ignoreCurrent was set.
+func (w *ShardedRollingWindow) Reduce(fn func(b *Bucket)) {
+	for _, shard := range w.shards {
+		shard.lock.RLock()
+	}
+	defer func() {
+		for _, shard := range w.shards {
+			shard.lock.RUnlock()
+		}
+	}()
+
+	now := w.shards[0].clock.Now()
+	merged := make([]Bucket, 0, w.shards[0].size)
+	for _, shard := range w.shards {
+		i := 0
+		shard.reduceAt(now, func(b *Bucket) {
+			if i == len(merged) {
+				merged = append(merged, Bucket{})
+				if b.Hist != nil {
+					merged[i].Hist = NewHistogram()
+				}
+			}
+			mb := &merged[i]
+			mb.Sum += b.Sum
+			mb.Count += b.Count
+			if mb.Hist != nil {
+				mb.Hist.Merge(b.Hist)
+			}
+			i++
+		})
+	}
+	for i := range merged {
+		fn(&merged[i])
+	}
+}
+
+// Quantile returns the q-quantile of all values in the buckets Reduce runs on,
+// it returns 0 unless the histogram is enabled.
+func (w *ShardedRollingWindow) Quantile(q float64) float64 {
+	h := NewHistogram()
+	w.Reduce(func(b *Bucket) {
+		h.Merge(b.Hist)
+	})
+	return h.Quantile(q)
+}
+
+// EnableHistogram lets buckets of all shards record the distribution of values from now on.
+func (w *ShardedRollingWindow) EnableHistogram() {
+	for _, shard := range w.shards {
+		shard.EnableHistogram()
+	}
+}
+
+// DisableHistogram stops recording the distribution and releases the histograms of all shards.
+func (w *ShardedRollingWindow) DisableHistogram() {
+	for _, shard := range w.shards {
+		shard.DisableHistogram()
+	}
+}
+
+// Resize changes the bucket layout of all shards, existing buckets are merged into the new buckets covering their time.
+func (w *ShardedRollingWindow) Resize(size int, interval time.Duration) {
+	if size < 1 {
+		panic("size must be greater than 0")
+	}
+	for _, shard := range w.shards {
+		shard.lock.Lock()
+	}
+	defer func() {
+		for _, shard := range w.shards {
+			shard.lock.Unlock()
+		}
+	}()
+	now := w.shards[0].clock.Now()
+	for _, shard := range w.shards {
+		shard.resizeAt(now, size, interval)
+	}
+}
+
+// Snapshot exports the state of the window, the shards are merged.
+func (w *ShardedRollingWindow) Snapshot() WindowSnapshot {
+	for _, shard := range w.shards {
+		shard.lock.Lock()
+	}
+	defer func() {
+		for _, shard := range w.shards {
+			shard.lock.Unlock()
+		}
+	}()
+	now := w.shards[0].clock.Now()
+	s := w.shards[0].snapshotAt(now)
+	for _, shard := range w.shards[1:] {
+		// shards share the time grid, so their buckets are aligned as of now
+		for i, b := range shard.snapshotAt(now).Buckets {
+			s.Buckets[i].Sum += b.Sum
+			s.Buckets[i].Count += b.Count
+		}
+	}
+	return s
+}
+
+// Restore merges the buckets of s into the first shard.
+func (w *ShardedRollingWindow) Restore(s WindowSnapshot) {
+	w.shards[0].Restore(s)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listWindow(w Window) []float64 {
+	var buckets []float64
+	w.Reduce(func(b *Bucket) {
+		buckets = append(buckets, b.Sum)
+	})
+	return buckets
+}
+
+func TestShardedRollingWindow(t *testing.T) {
+	assert.Panics(t, func() {
+		NewShardedRollingWindow(0, 3, time.Second)
+	})
+	for _, ignoreCurrent := range []bool{false, true} {
+		var opts []RollingWindowOption
+		if ignoreCurrent {
+			opts = append(opts, IgnoreCurrentBucket())
+		}
+		sharded := NewShardedRollingWindow(4, 3, 20*time.Millisecond, opts...)
+		single := NewRollingWindow(3, 20*time.Millisecond, opts...)
+		single.lastTime = sharded.shards[0].lastTime
+		assert.Equal(t, listWindow(single), listWindow(sharded))
+		for i := 1; i <= 5; i++ {
+			sharded.Add(float64(i))
+			single.Add(float64(i))
+		}
+		assert.Equal(t, listWindow(single), listWindow(sharded))
+		time.Sleep(20 * time.Millisecond)
+		sharded.Add(10)
+		single.Add(10)
+		assert.Equal(t, listWindow(single), listWindow(sharded))
+		// only some of the shards are rolled
+		time.Sleep(40 * time.Millisecond)
+		sharded.Add(1)
+		single.Add(1)
+		assert.Equal(t, listWindow(single), listWindow(sharded))
+	}
+}
+
+func TestShardedRollingWindowConcurrent(t *testing.T) {
+	w := NewShardedRollingWindow(8, 10, time.Second, WithHistogram())
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				w.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	var sum float64
+	var count int64
+	w.Reduce(func(b *Bucket) {
+		sum += b.Sum
+		count += b.Count
+	})
+	assert.Equal(t, float64(16000), sum)
+	assert.Equal(t, int64(16000), count)
+	assert.Equal(t, float64(1), w.Quantile(0.99))
+
+	w.Resize(5, 2*time.Second)
+	assert.Equal(t, float64(16000), listWindow(w)[4])
+}
+
+func benchmarkWindowAdd(b *testing.B, w Window) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w.Add(1)
+		}
+	})
+}
+
+func BenchmarkRollingWindowAddParallel(b *testing.B) {
+	benchmarkWindowAdd(b, NewRollingWindow(100, 100*time.Millisecond))
+}
+
+func BenchmarkShardedRollingWindowAddParallel(b *testing.B) {
+	benchmarkWindowAdd(b, NewShardedRollingWindow(16, 100, 100*time.Millisecond))
+}
+
+func BenchmarkRollingWindowReduce(b *testing.B) {
+	w := NewRollingWindow(100, 100*time.Millisecond)
+	w.Add(1)
+	for i := 0; i < b.N; i++ {
+		w.Reduce(func(b *Bucket) {})
+	}
+}
+
+func BenchmarkShardedRollingWindowReduce(b *testing.B) {
+	w := NewShardedRollingWindow(16, 100, 100*time.Millisecond)
+	w.Add(1)
+	for i := 0; i < b.N; i++ {
+		w.Reduce(func(b *Bucket) {})
+	}
+}
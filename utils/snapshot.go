@@ -0,0 +1,157 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+const snapshotVersion = 1
+
+// ErrInvalidSnapshot is returned when decoding a malformed snapshot
+var ErrInvalidSnapshot = errors.New("invalid window snapshot")
+
+// WindowSnapshot is the state of a window, the buckets are ordered from the oldest to the one starting at Time.
+// The distribution of the histogram isn't kept.
+type WindowSnapshot struct {
+	Interval time.Duration    `json:"interval"`
+	Time     time.Time        `json:"time"`
+	Buckets  []BucketSnapshot `json:"buckets"`
+}
+
+// BucketSnapshot is the sum and num of additions of a bucket
+type BucketSnapshot struct {
+	Sum   float64 `json:"sum"`
+	Count int64   `json:"count"`
+}
+
+// Snapshot exports the state of the window
+func (rw *RollingWindow) Snapshot() WindowSnapshot {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+	return rw.snapshotAt(rw.clock.Now())
+}
+
+// snapshotAt exports the state as of now, the caller must hold the lock.
+func (rw *RollingWindow) snapshotAt(now time.Time) WindowSnapshot {
+	rw.updateOffsetAt(now)
+	s := WindowSnapshot{
+		Interval: rw.interval,
+		Time:     rw.lastTime,
+		Buckets:  make([]BucketSnapshot, rw.size),
+	}
+	for i := 0; i < rw.size; i++ {
+		b := rw.win.buckets[(rw.offset+1+i)%rw.size]
+		s.Buckets[i] = BucketSnapshot{Sum: b.Sum, Count: b.Count}
+	}
+	return s
+}
+
+// Restore spreads the buckets of s over the buckets covering their time, e.g. to seed a fresh window
+// from a peer or the last snapshot written at shutdown. Buckets expired since s.Time are dropped,
+// and coarser buckets are split evenly over the finer ones they cover.
+func (rw *RollingWindow) Restore(s WindowSnapshot) {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+	rw.restoreAt(rw.clock.Now(), s)
+}
+
+// restoreAt restores s as of now, the caller must hold the lock.
+func (rw *RollingWindow) restoreAt(now time.Time, s WindowSnapshot) {
+	if s.Interval <= 0 {
+		return
+	}
+	rw.updateOffsetAt(now)
+	// a snapshot from a peer whose clock is ahead is taken as of now
+	current := s.Time
+	if current.After(now) {
+		current = now
+	}
+	for i, b := range s.Buckets {
+		start := current.Add(-time.Duration(len(s.Buckets)-1-i) * s.Interval)
+		end := start.Add(s.Interval)
+		if i == len(s.Buckets)-1 && now.Before(end) {
+			// the current bucket of s only covers the time until now
+			end = now
+		}
+		rw.spread(start, end, b.Sum, b.Count, nil)
+	}
+}
+
+// MarshalBinary encodes s compactly: the version, interval, time and the buckets in varints,
+// except the sums in IEEE 754.
+func (s WindowSnapshot) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 1, 1+3*binary.MaxVarintLen64+len(s.Buckets)*(8+binary.MaxVarintLen64))
+	buf[0] = snapshotVersion
+	var tmp [binary.MaxVarintLen64]byte
+	appendVarint := func(v int64) {
+		buf = append(buf, tmp[:binary.PutVarint(tmp[:], v)]...)
+	}
+	appendVarint(int64(s.Interval))
+	appendVarint(s.Time.UnixNano())
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(s.Buckets)))]...)
+	for _, b := range s.Buckets {
+		binary.LittleEndian.PutUint64(tmp[:8], math.Float64bits(b.Sum))
+		buf = append(buf, tmp[:8]...)
+		appendVarint(b.Count)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes s encoded by MarshalBinary
+func (s *WindowSnapshot) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 || data[0] != snapshotVersion {
+		return ErrInvalidSnapshot
+	}
+	data = data[1:]
+	next := func() int64 {
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			data = nil
+			return 0
+		}
+		data = data[n:]
+		return v
+	}
+	interval, unixNano := next(), next()
+	size, n := binary.Uvarint(data)
+	if n <= 0 || size > uint64(len(data)) {
+		return ErrInvalidSnapshot
+	}
+	data = data[n:]
+	buckets := make([]BucketSnapshot, size)
+	for i := range buckets {
+		if len(data) < 8 {
+			return ErrInvalidSnapshot
+		}
+		buckets[i].Sum = math.Float64frombits(binary.LittleEndian.Uint64(data))
+		data = data[8:]
+		buckets[i].Count = next()
+		if data == nil {
+			return ErrInvalidSnapshot
+		}
+	}
+	*s = WindowSnapshot{
+		Interval: time.Duration(interval),
+		Time:     time.Unix(0, unixNano),
+		Buckets:  buckets,
+	}
+	return nil
+}
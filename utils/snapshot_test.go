@@ -0,0 +1,105 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollingWindowSnapshot(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	r := NewRollingWindow(3, time.Second, WithClock(clock))
+	r.Add(1)
+	clock.Advance(time.Second)
+	r.Add(2)
+	r.Add(3)
+	s := r.Snapshot()
+	assert.Equal(t, time.Second, s.Interval)
+	assert.Equal(t, []BucketSnapshot{{0, 0}, {1, 1}, {5, 2}}, s.Buckets)
+
+	// restored a second later, the oldest bucket expired
+	clock.Advance(time.Second)
+	fresh := NewRollingWindow(3, time.Second, WithClock(clock))
+	fresh.Restore(s)
+	assert.Equal(t, []float64{1, 5, 0}, listWindow(fresh))
+
+	// into a different layout, both buckets fall into the previous 2s bucket
+	fresh = NewRollingWindow(2, 2*time.Second, WithClock(clock))
+	fresh.Restore(s)
+	assert.Equal(t, []float64{6, 0}, listWindow(fresh))
+
+	// expired
+	clock.Advance(time.Minute)
+	fresh = NewRollingWindow(3, time.Second, WithClock(clock))
+	fresh.Restore(s)
+	assert.Equal(t, []float64{0, 0, 0}, listWindow(fresh))
+}
+
+func TestRollingWindowRestoreFiner(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	r := NewRollingWindow(3, time.Second, WithClock(clock))
+	for i := 0; i < 10; i++ {
+		r.Add(10)
+	}
+	clock.Advance(time.Second)
+	s := r.Snapshot()
+
+	// every 1s bucket is split over the 100ms buckets it covers
+	fresh := NewRollingWindow(10, 100*time.Millisecond, WithClock(clock))
+	fresh.Restore(s)
+	assert.Equal(t, []float64{10, 10, 10, 10, 10, 10, 10, 10, 10, 0}, listWindow(fresh))
+	var count int64
+	fresh.Reduce(func(b *Bucket) {
+		count += b.Count
+	})
+	assert.Equal(t, int64(9), count)
+}
+
+func TestShardedRollingWindowSnapshot(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	w := NewShardedRollingWindow(4, 3, time.Second, WithClock(clock))
+	for i := 0; i < 8; i++ {
+		w.Add(1)
+	}
+	s := w.Snapshot()
+	assert.Equal(t, []BucketSnapshot{{0, 0}, {0, 0}, {8, 8}}, s.Buckets)
+	fresh := NewShardedRollingWindow(2, 3, time.Second, WithClock(clock))
+	fresh.Restore(s)
+	assert.Equal(t, []float64{0, 0, 8}, listWindow(fresh))
+}
+
+func TestWindowSnapshotBinary(t *testing.T) {
+	s := WindowSnapshot{
+		Interval: 100 * time.Millisecond,
+		Time:     time.Unix(0, 1666666666666666666),
+		Buckets:  []BucketSnapshot{{0, 0}, {1.5, 1}, {1e6, 1000}},
+	}
+	data, err := s.MarshalBinary()
+	assert.Nil(t, err)
+	var decoded WindowSnapshot
+	assert.Nil(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, s.Interval, decoded.Interval)
+	assert.True(t, s.Time.Equal(decoded.Time))
+	assert.Equal(t, s.Buckets, decoded.Buckets)
+
+	assert.Equal(t, ErrInvalidSnapshot, decoded.UnmarshalBinary(nil))
+	assert.Equal(t, ErrInvalidSnapshot, decoded.UnmarshalBinary([]byte{2}))
+	assert.Equal(t, ErrInvalidSnapshot, decoded.UnmarshalBinary(data[:len(data)-3]))
+}
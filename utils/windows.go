@@ -17,10 +17,23 @@
 package utils
 
 import (
+	"math"
 	"sync"
 	"time"
 )
 
+// Window is a rolling window of buckets, implemented by RollingWindow and ShardedRollingWindow.
+type Window interface {
+	Add(v float64)
+	Reduce(fn func(b *Bucket))
+	Resize(size int, interval time.Duration)
+	Quantile(q float64) float64
+	EnableHistogram()
+	DisableHistogram()
+	Snapshot() WindowSnapshot
+	Restore(s WindowSnapshot)
+}
+
 type (
 	RollingWindowOption func(rollingWindow *RollingWindow)
 	RollingWindow       struct {
@@ -31,6 +44,8 @@ type (
 		offset        int
 		lastTime      time.Time
 		ignoreCurrent bool
+		histogram     bool
+		clock         Clock
 	}
 )
 
@@ -42,13 +57,14 @@ func NewRollingWindow(size int, interval time.Duration, opts ...RollingWindowOpt
 
 	w := &RollingWindow{
 		size:     size,
-		win:      newWindow(size),
 		interval: interval,
-		lastTime: time.Now(),
+		clock:    SystemClock,
 	}
 	for _, opt := range opts {
 		opt(w)
 	}
+	w.lastTime = w.clock.Now()
+	w.win = newWindow(size, w.histogram)
 	return w
 }
 
@@ -64,9 +80,14 @@ func (rw *RollingWindow) Add(v float64) {
 func (rw *RollingWindow) Reduce(fn func(b *Bucket)) {
 	rw.lock.RLock()
 	defer rw.lock.RUnlock()
+	rw.reduceAt(rw.clock.Now(), fn)
+}
 
+// reduceAt runs fn on all buckets as of now, the caller must hold the lock.
+// The first bucket is always size-1 intervals before now, so windows sharing the time grid are aligned.
+func (rw *RollingWindow) reduceAt(now time.Time, fn func(b *Bucket)) {
 	var diff int
-	span := rw.span()
+	span := rw.spanAt(now)
 	// ignore current bucket
 	if span == 0 && rw.ignoreCurrent {
 		diff = rw.size - 1
@@ -80,9 +101,132 @@ func (rw *RollingWindow) Reduce(fn func(b *Bucket)) {
 	}
 }
 
+// Quantile returns the q-quantile of all values in the buckets Reduce runs on,
+// it returns 0 unless the histogram is enabled.
+func (rw *RollingWindow) Quantile(q float64) float64 {
+	h := NewHistogram()
+	rw.Reduce(func(b *Bucket) {
+		h.Merge(b.Hist)
+	})
+	return h.Quantile(q)
+}
+
+// EnableHistogram lets buckets record the distribution of values from now on, existing values are not recorded.
+func (rw *RollingWindow) EnableHistogram() {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+	if rw.histogram {
+		return
+	}
+	rw.histogram = true
+	for _, b := range rw.win.buckets {
+		b.Hist = NewHistogram()
+	}
+}
+
+// DisableHistogram stops recording the distribution and releases the histograms
+func (rw *RollingWindow) DisableHistogram() {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+	rw.histogram = false
+	for _, b := range rw.win.buckets {
+		b.Hist = nil
+	}
+}
+
+// Resize changes the bucket layout to size buckets with time interval,
+// existing buckets are spread over the new buckets by the overlap of their time.
+func (rw *RollingWindow) Resize(size int, interval time.Duration) {
+	if size < 1 {
+		panic("size must be greater than 0")
+	}
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+	rw.resizeAt(rw.clock.Now(), size, interval)
+}
+
+// resizeAt resizes the window as of now, the caller must hold the lock.
+// The new current bucket is the one of now on the new grid anchored at the current bucket.
+func (rw *RollingWindow) resizeAt(now time.Time, size int, interval time.Duration) {
+	rw.updateOffsetAt(now)
+
+	prev, prevSize, prevInterval, prevOffset, prevTime := rw.win, rw.size, rw.interval, rw.offset, rw.lastTime
+	rw.win = newWindow(size, rw.histogram)
+	rw.size = size
+	rw.interval = interval
+	rw.offset = size - 1
+	rw.lastTime = prevTime.Add(now.Sub(prevTime) / interval * interval)
+	for i := 0; i < prevSize; i++ {
+		// the bucket i intervals before the current one
+		b := prev.buckets[(prevOffset-i+prevSize)%prevSize]
+		start := prevTime.Add(-time.Duration(i) * prevInterval)
+		end := start.Add(prevInterval)
+		if i == 0 && now.Before(end) {
+			// the current bucket only covers the time until now
+			end = now
+		}
+		rw.spread(start, end, b.Sum, b.Count, b.Hist)
+	}
+}
+
+// spread adds sum and count added during [start, end) to the buckets overlapping it, in proportion to the overlap.
+// Buckets expired are skipped, and the histogram is merged into every bucket overlapping it,
+// as its distribution is assumed the same over the time. The caller must hold the lock.
+func (rw *RollingWindow) spread(start, end time.Time, sum float64, count int64, hist *Histogram) {
+	if count == 0 && sum == 0 {
+		return
+	}
+	// age returns the number of intervals the bucket of t is before the current one
+	age := func(t time.Time) int {
+		d := rw.lastTime.Sub(t)
+		if d <= 0 {
+			return 0
+		}
+		return int((d + rw.interval - 1) / rw.interval)
+	}
+	total := end.Sub(start)
+	first, last := age(start), age(start)
+	if total > 0 {
+		last = age(end.Add(-1))
+	}
+	var covered time.Duration
+	var spreadSum float64
+	var spreadCount int64
+	for j := first; j >= last; j-- {
+		part := 1.0
+		if total > 0 && j > last {
+			bucketStart := rw.lastTime.Add(-time.Duration(j) * rw.interval)
+			bucketEnd := bucketStart.Add(rw.interval)
+			if start.After(bucketStart) {
+				bucketStart = start
+			}
+			covered += bucketEnd.Sub(bucketStart)
+			part = float64(covered) / float64(total)
+		}
+		// parts are cumulative, so the rounding errors of counts don't add up
+		s, c := sum*part-spreadSum, int64(math.Round(float64(count)*part))-spreadCount
+		spreadSum += s
+		spreadCount += c
+		if j >= rw.size {
+			continue
+		}
+		b := rw.win.buckets[(rw.offset-j+rw.size)%rw.size]
+		b.Sum += s
+		b.Count += c
+		if b.Hist != nil {
+			b.Hist.Merge(hist)
+		}
+	}
+}
+
 // span Return the elapsed time interval
 func (rw *RollingWindow) span() int {
-	offset := int(time.Since(rw.lastTime) / rw.interval)
+	return rw.spanAt(rw.clock.Now())
+}
+
+// spanAt returns the elapsed time interval until now
+func (rw *RollingWindow) spanAt(now time.Time) int {
+	offset := int(now.Sub(rw.lastTime) / rw.interval)
 	if 0 <= offset && offset < rw.size {
 		return offset
 	}
@@ -91,7 +235,12 @@ func (rw *RollingWindow) span() int {
 
 // updateOffset Update the offset of window
 func (rw *RollingWindow) updateOffset() {
-	span := rw.span()
+	rw.updateOffsetAt(rw.clock.Now())
+}
+
+// updateOffsetAt updates the offset of window as of now
+func (rw *RollingWindow) updateOffsetAt(now time.Time) {
+	span := rw.spanAt(now)
 	if span <= 0 {
 		return
 	}
@@ -103,23 +252,31 @@ func (rw *RollingWindow) updateOffset() {
 	}
 
 	rw.offset = (offset + span) % rw.size
-	rw.lastTime = rw.lastTime.Add(time.Since(rw.lastTime.Add(time.Since(rw.lastTime) % rw.interval)))
+	rw.lastTime = now.Add(-(now.Sub(rw.lastTime) % rw.interval))
 }
 
-// Bucket defines the bucket that holds sum and num of additions.
+// Bucket defines the bucket that holds sum and num of additions,
+// and their distribution in Hist if the histogram is enabled.
 type Bucket struct {
 	Sum   float64
 	Count int64
+	Hist  *Histogram
 }
 
 func (b *Bucket) add(v float64) {
 	b.Sum += v
 	b.Count++
+	if b.Hist != nil {
+		b.Hist.Add(v)
+	}
 }
 
 func (b *Bucket) reset() {
 	b.Sum = 0
 	b.Count = 0
+	if b.Hist != nil {
+		b.Hist.Reset()
+	}
 }
 
 type window struct {
@@ -127,10 +284,13 @@ type window struct {
 	size    int
 }
 
-func newWindow(size int) *window {
+func newWindow(size int, histogram bool) *window {
 	buckets := make([]*Bucket, size)
 	for i := 0; i < size; i++ {
 		buckets[i] = new(Bucket)
+		if histogram {
+			buckets[i].Hist = NewHistogram()
+		}
 	}
 	return &window{
 		buckets: buckets,
@@ -158,3 +318,17 @@ func IgnoreCurrentBucket() RollingWindowOption {
 		w.ignoreCurrent = true
 	}
 }
+
+// WithHistogram lets buckets record the distribution of values, so Quantile works.
+func WithHistogram() RollingWindowOption {
+	return func(w *RollingWindow) {
+		w.histogram = true
+	}
+}
+
+// WithClock lets the window roll by clock instead of the system clock.
+func WithClock(clock Clock) RollingWindowOption {
+	return func(w *RollingWindow) {
+		w.clock = clock
+	}
+}
@@ -100,3 +100,102 @@ func TestRollingWindowsAvg(t *testing.T) {
 	r.Add(5)
 	assert.Equal(t, float64(1), list())
 }
+
+func TestRollingWindowResize(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	r := NewRollingWindow(4, time.Second, WithClock(clock))
+	list := func() []float64 {
+		var buckets []float64
+		r.Reduce(func(b *Bucket) {
+			buckets = append(buckets, b.Sum)
+		})
+		return buckets
+	}
+	r.win.buckets = []*Bucket{{Sum: 1, Count: 1}, {Sum: 2, Count: 1}, {Sum: 3, Count: 1}, {Sum: 4, Count: 1}}
+	r.offset = 3
+	// merge every two buckets on the grid of the current bucket
+	clock.Advance(500 * time.Millisecond)
+	r.Resize(3, 2*time.Second)
+	assert.Equal(t, []float64{1, 5, 4}, list())
+	// older buckets are dropped
+	r.Resize(1, time.Second)
+	assert.Equal(t, []float64{4}, list())
+	assert.Panics(t, func() {
+		r.Resize(0, time.Second)
+	})
+}
+
+func TestRollingWindowResizeFiner(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	r := NewRollingWindow(2, time.Second, WithClock(clock))
+	for i := 0; i < 10; i++ {
+		r.Add(10)
+	}
+	clock.Advance(time.Second)
+	for i := 0; i < 4; i++ {
+		r.Add(10)
+	}
+	clock.Advance(400 * time.Millisecond)
+	// each bucket is spread over the buckets it covers, the current one only covers the time until now
+	r.Resize(5, 200*time.Millisecond)
+	var counts []int64
+	r.Reduce(func(b *Bucket) {
+		counts = append(counts, b.Count)
+	})
+	assert.Equal(t, []float64{20, 20, 20, 20, 0}, listWindow(r))
+	assert.Equal(t, []int64{2, 2, 2, 2, 0}, counts)
+	clock.Advance(200 * time.Millisecond)
+	assert.Equal(t, []float64{20, 20, 20, 0}, listWindow(r))
+}
+
+func TestRollingWindowQuantile(t *testing.T) {
+	r := NewRollingWindow(3, time.Second, WithHistogram())
+	for i := 1; i <= 100; i++ {
+		r.Add(float64(i))
+	}
+	assert.InDelta(t, 99, r.Quantile(0.99), 99*0.125)
+
+	// without histogram
+	r = NewRollingWindow(3, time.Second)
+	r.Add(1)
+	assert.Equal(t, float64(0), r.Quantile(0.99))
+	r.EnableHistogram()
+	r.Add(10)
+	assert.Equal(t, float64(10), r.Quantile(0.99))
+
+	// histograms are merged by Resize
+	r.win.buckets[0].Hist.Add(20)
+	r.Resize(1, 3*time.Second)
+	assert.Equal(t, float64(20), r.Quantile(1))
+	// and released when disabled
+	r.DisableHistogram()
+	for _, b := range r.win.buckets {
+		assert.Nil(t, b.Hist)
+	}
+	r.Add(30)
+	assert.Equal(t, float64(0), r.Quantile(1))
+}
+
+func TestRollingWindowWithClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	r := NewRollingWindow(3, time.Second, WithClock(clock))
+	list := func() []float64 {
+		var buckets []float64
+		r.Reduce(func(b *Bucket) {
+			buckets = append(buckets, b.Sum)
+		})
+		return buckets
+	}
+	r.Add(1)
+	clock.Advance(999 * time.Millisecond)
+	r.Add(2)
+	assert.Equal(t, []float64{0, 0, 3}, list())
+	clock.Advance(time.Millisecond)
+	r.Add(4)
+	assert.Equal(t, []float64{0, 3, 4}, list())
+	// the oldest buckets expire without Add
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, []float64{4}, list())
+	clock.Advance(time.Second)
+	assert.Equal(t, []float64(nil), list())
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import "math"
+
+// warmUpElapsed returns the ratio of WarmUp elapsed since the limiter was created, 1 if warmed up
+func (l *BBR) warmUpElapsed(opts options) float64 {
+	if opts.WarmUp <= 0 {
+		return 1
+	}
+	elapsed := l.clock.Now().Sub(l.created)
+	if elapsed >= opts.WarmUp {
+		return 1
+	}
+	return float64(elapsed) / float64(opts.WarmUp)
+}
+
+// admitAll reports whether requests are admitted without checks while warming up without WarmUpCapacity
+func (l *BBR) admitAll(opts options) bool {
+	return opts.WarmUpCapacity <= 0 && l.warmUpElapsed(opts) < 1
+}
+
+// warmUpCapacity returns the capacity estimated by the windows, at least the seeded WarmUpCapacity
+// fading out linearly while warming up.
+func (l *BBR) warmUpCapacity(opts options, capacity int64) int64 {
+	if opts.WarmUpCapacity <= 0 {
+		return capacity
+	}
+	elapsed := l.warmUpElapsed(opts)
+	if elapsed >= 1 {
+		return capacity
+	}
+	if seeded := int64(math.Ceil(float64(opts.WarmUpCapacity) * (1 - elapsed))); seeded > capacity {
+		return seeded
+	}
+	return capacity
+}
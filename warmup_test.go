@@ -0,0 +1,58 @@
+/*
+ * Copyright 2022 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hertz-contrib/limiter/utils"
+)
+
+func TestBBRWarmUpAdmitAll(t *testing.T) {
+	cpu := int64(900)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := newDropTestLimiter(clock, &cpu, WithWarmUp(10*time.Second, 0))
+	assert.Equal(t, false, bbr.shouldDrop())
+	// one bucket of 100ms has passed since created
+	clock.Advance(9*time.Second - 100*time.Millisecond)
+	assert.Equal(t, false, bbr.shouldDrop())
+	clock.Advance(time.Second)
+	assert.Equal(t, true, bbr.shouldDrop())
+}
+
+func TestBBRWarmUpCapacity(t *testing.T) {
+	cpu := int64(900)
+	clock := utils.NewFakeClock(time.Now())
+	bbr := newDropTestLimiter(clock, &cpu, WithWarmUp(10*time.Second, 200))
+	// capacity estimated by the windows is 1, and one bucket of 100ms has passed since created
+	assert.Equal(t, int64(198), bbr.maxInFlight())
+	assert.Equal(t, false, bbr.shouldDrop())
+
+	// fading out
+	clock.Advance(5*time.Second - 100*time.Millisecond)
+	assert.Equal(t, int64(100), bbr.maxInFlight())
+	assert.Equal(t, false, bbr.shouldDrop())
+	clock.Advance(3 * time.Second)
+	assert.Equal(t, int64(40), bbr.maxInFlight())
+	assert.Equal(t, true, bbr.shouldDrop())
+
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, int64(1), bbr.maxInFlight())
+}